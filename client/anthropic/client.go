@@ -0,0 +1,534 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+	"github.com/A-pen-app/ai-client/util"
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// defaultMaxTokens is used when the caller does not set MaxTokens, since the
+// messages API requires an explicit output budget.
+const defaultMaxTokens = 4096
+
+// maxTemperature is the upper bound Anthropic accepts for temperature.
+const maxTemperature = 1.0
+
+// maxImages is the default AIClientOptions.MaxImages, the most images the
+// Anthropic API accepts in one request.
+const maxImages = 100
+
+// Client wraps the Anthropic messages API and implements the AIClient interface
+type Client struct {
+	client       *anthropic.Client
+	defaultModel anthropic.Model
+	// maxImageDim and jpegQuality configure image preprocessing; a zero
+	// maxImageDim disables it.
+	maxImageDim int
+	jpegQuality int
+	// convertHEIC converts HEIC images to JPEG before they are attached.
+	convertHEIC bool
+	// imageResolver fetches ImageUrls; nil uses util.HTTPImageResolver.
+	imageResolver util.ImageResolver
+	closed        atomic.Bool
+}
+
+// NewClient creates a new Anthropic API client
+func NewClient(apiKey string, model string, opts ...Option) (store.AIClient, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("anthropic API key cannot be empty")
+	}
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	client := anthropic.NewClient(option.WithAPIKey(apiKey))
+
+	if model == "" {
+		model = string(anthropic.ModelClaudeSonnet4_5)
+	}
+
+	return &Client{
+		client:        &client,
+		defaultModel:  anthropic.Model(model),
+		maxImageDim:   o.maxImageDim,
+		jpegQuality:   o.jpegQuality,
+		convertHEIC:   o.convertHEIC,
+		imageResolver: o.imageResolver,
+	}, nil
+}
+
+func (c *Client) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	text, _, err := c.GenerateWithUsage(ctx, message, opts)
+	return text, err
+}
+
+// GenerateChat replies to an ordered conversation, mapped onto a message
+// with models.NewChatMessage.
+func (c *Client) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+// GenerateWithUsage behaves like Generate and also reports token usage from
+// the messages response.
+func (c *Client) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	return result.Text, result.Usage, err
+}
+
+// GenerateWithTools returns the full result, including any tool_use blocks
+// the model produced for opts.Tools.
+func (c *Client) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	ctx, cancel := util.WithRequestTimeout(ctx, opts.RequestTimeout)
+	defer cancel()
+
+	result, err := c.generate(ctx, message, opts)
+	return result, util.TimeoutError(ctx, err)
+}
+
+func (c *Client) generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	if c.client == nil {
+		return models.GenerateResult{}, fmt.Errorf("anthropic client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return models.GenerateResult{}, models.ErrClientClosed
+	}
+
+	params, err := c.buildParams(ctx, message, opts)
+	if err != nil {
+		return models.GenerateResult{}, err
+	}
+
+	resp, err := c.client.Messages.New(ctx, params)
+	if err != nil {
+		return models.GenerateResult{}, wrapError(err)
+	}
+
+	result := models.GenerateResult{
+		Usage: models.Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+		FinishReason: finishReason(string(resp.StopReason)),
+	}
+	if opts.IncludeRawResponse {
+		result.Raw = json.RawMessage(resp.RawJSON())
+	}
+	if result.FinishReason == models.FinishReasonContentFilter {
+		return result, models.NewContentFilteredError("anthropic", string(resp.StopReason))
+	}
+
+	if len(resp.Content) == 0 {
+		return result, &models.AIError{Kind: models.ErrorKindEmptyResponse, Provider: "anthropic", Err: errors.New("empty response content from Anthropic")}
+	}
+
+	var resultText strings.Builder
+	resultText.WriteString(message.AssistantPrefill)
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			resultText.WriteString(block.Text)
+		case "tool_use":
+			result.ToolCalls = append(result.ToolCalls, models.ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: block.Input,
+			})
+		}
+	}
+	result.Text = resultText.String()
+
+	return result, nil
+}
+
+// GenerateN returns every candidate requested with opts.N. With N <= 1 it
+// returns the single Generate result.
+func (c *Client) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Texts(), nil
+}
+
+// StreamGenerate streams the response text as it is produced. The returned
+// channel is closed once the stream ends; the last chunk has Done set and
+// carries any provider or context error.
+func (c *Client) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	ctx, cancel := util.WithRequestTimeout(ctx, opts.RequestTimeout)
+
+	ch, err := c.stream(ctx, message, opts, cancel)
+	if err != nil {
+		cancel()
+		return nil, util.TimeoutError(ctx, err)
+	}
+	return ch, nil
+}
+
+// stream starts the provider stream. On success the producing goroutine
+// owns cancel and calls it when the stream ends.
+func (c *Client) stream(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions, cancel context.CancelFunc) (<-chan models.StreamChunk, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("anthropic client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return nil, models.ErrClientClosed
+	}
+
+	params, err := c.buildParams(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := c.client.Messages.NewStreaming(ctx, params)
+	if err := stream.Err(); err != nil {
+		stream.Close()
+		return nil, wrapError(err)
+	}
+
+	ch := make(chan models.StreamChunk)
+	go func() {
+		defer close(ch)
+		defer cancel()
+		defer stream.Close()
+
+		if message.AssistantPrefill != "" && !util.SendChunk(ctx, ch, models.StreamChunk{Text: message.AssistantPrefill}) {
+			util.FinishStream(ctx, ch, nil)
+			return
+		}
+
+		for stream.Next() {
+			event := stream.Current()
+			if event.Type != "content_block_delta" || event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				continue
+			}
+			if !util.SendChunk(ctx, ch, models.StreamChunk{Text: event.Delta.Text}) {
+				util.FinishStream(ctx, ch, nil)
+				return
+			}
+		}
+
+		util.FinishStream(ctx, ch, wrapError(stream.Err()))
+	}()
+
+	return ch, nil
+}
+
+// BuildRequest returns the anthropic.MessageNewParams Generate would send
+// for message and opts, with images downloaded and preprocessed as
+// configured, without calling the API. The result can be passed to
+// GenerateRaw.
+func (c *Client) BuildRequest(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (any, error) {
+	return c.buildParams(ctx, message, opts)
+}
+
+// GenerateRaw sends a messages request built by the caller, bypassing the
+// AIChatMessage mapping, e.g. to prefill the assistant turn, and returns the
+// text blocks of the reply. params must be an anthropic.MessageNewParams
+// (or a pointer to one); an empty Model and a zero MaxTokens use the client
+// defaults. This is an escape hatch specific to this client.
+func (c *Client) GenerateRaw(ctx context.Context, params any) (string, error) {
+	if c.client == nil {
+		return "", fmt.Errorf("anthropic client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return "", models.ErrClientClosed
+	}
+
+	var request anthropic.MessageNewParams
+	switch p := params.(type) {
+	case anthropic.MessageNewParams:
+		request = p
+	case *anthropic.MessageNewParams:
+		request = *p
+	default:
+		return "", fmt.Errorf("anthropic GenerateRaw expects anthropic.MessageNewParams, got %T", params)
+	}
+	if request.Model == "" {
+		request.Model = c.defaultModel
+	}
+	if request.MaxTokens == 0 {
+		request.MaxTokens = defaultMaxTokens
+	}
+
+	resp, err := c.client.Messages.New(ctx, request)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	if finishReason(string(resp.StopReason)) == models.FinishReasonContentFilter {
+		return "", models.NewContentFilteredError("anthropic", string(resp.StopReason))
+	}
+
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String(), nil
+}
+
+// HealthCheck retrieves the default model, which verifies connectivity,
+// the API key and the model name without generating any tokens.
+func (c *Client) HealthCheck(ctx context.Context) error {
+	if c.client == nil {
+		return fmt.Errorf("anthropic client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return models.ErrClientClosed
+	}
+
+	if _, err := c.client.Models.Get(ctx, string(c.defaultModel), anthropic.ModelGetParams{}); err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
+
+// Close makes later calls fail with models.ErrClientClosed. The Anthropic
+// client holds no resources that need releasing. It is safe to call more
+// than once.
+func (c *Client) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// buildParams maps an AIChatMessage and options onto a messages API request.
+func (c *Client) buildParams(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (anthropic.MessageNewParams, error) {
+	opts = util.AdaptMaxTokens(ctx, opts)
+
+	if err := opts.ValidateSampling(maxTemperature); err != nil {
+		return anthropic.MessageNewParams{}, err
+	}
+
+	if err := opts.ValidateResponseFormat(); err != nil {
+		return anthropic.MessageNewParams{}, err
+	}
+
+	if opts.Seed != nil {
+		return anthropic.MessageNewParams{}, fmt.Errorf("seed is not supported by anthropic")
+	}
+
+	if opts.FrequencyPenalty != nil || opts.PresencePenalty != nil {
+		return anthropic.MessageNewParams{}, fmt.Errorf("frequency and presence penalties are not supported by anthropic")
+	}
+
+	if opts.N > 1 {
+		return anthropic.MessageNewParams{}, fmt.Errorf("anthropic supports a single candidate, requested %d", opts.N)
+	}
+
+	if err := message.ValidateHistory(); err != nil {
+		return anthropic.MessageNewParams{}, err
+	}
+
+	if err := opts.ValidateImageCount("anthropic", message, maxImages); err != nil {
+		return anthropic.MessageNewParams{}, err
+	}
+
+	if message.HasAudio() {
+		return anthropic.MessageNewParams{}, fmt.Errorf("audio input is not supported by anthropic")
+	}
+
+	if len(message.DocumentUrls) > 0 {
+		return anthropic.MessageNewParams{}, fmt.Errorf("document input is not supported by anthropic")
+	}
+
+	if err := opts.ValidateModel("anthropic"); err != nil {
+		return anthropic.MessageNewParams{}, err
+	}
+
+	message = util.FoldSystemPrompt(message, opts)
+
+	model := c.defaultModel
+	if opts.Model != "" {
+		model = anthropic.Model(opts.Model)
+	}
+
+	if err := opts.UnsupportedReasoning("anthropic", string(model)); err != nil {
+		return anthropic.MessageNewParams{}, err
+	}
+
+	if err := opts.UnsupportedLogitBias("anthropic"); err != nil {
+		return anthropic.MessageNewParams{}, err
+	}
+
+	if opts.LogProbs {
+		return anthropic.MessageNewParams{}, fmt.Errorf("logprobs are not supported by anthropic")
+	}
+
+	maxTokens := opts.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+
+	blocks, err := c.userBlocks(ctx, message.Text, message)
+	if err != nil {
+		return anthropic.MessageNewParams{}, err
+	}
+
+	messages := make([]anthropic.MessageParam, 0, len(message.History)+2)
+	for _, turn := range message.History {
+		switch turn.Role {
+		case models.RoleUser:
+			if !turn.HasImages() {
+				messages = append(messages, anthropic.NewUserMessage(anthropic.NewTextBlock(turn.Text)))
+				break
+			}
+			turnBlocks, err := c.userBlocks(ctx, turn.Text, turn.ImageMessage(message.ImageMimeType))
+			if err != nil {
+				return anthropic.MessageNewParams{}, err
+			}
+			messages = append(messages, anthropic.NewUserMessage(turnBlocks...))
+		case models.RoleAssistant:
+			messages = append(messages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(turn.Text)))
+		default:
+			return anthropic.MessageNewParams{}, fmt.Errorf("unsupported turn role %q", turn.Role)
+		}
+	}
+	messages = append(messages, anthropic.NewUserMessage(blocks...))
+	if message.AssistantPrefill != "" {
+		messages = append(messages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(message.AssistantPrefill)))
+	}
+
+	params := anthropic.MessageNewParams{
+		Model:     model,
+		MaxTokens: maxTokens,
+		Messages:  messages,
+	}
+
+	for _, tool := range opts.Tools {
+		toolParam, err := toToolParam(tool)
+		if err != nil {
+			return anthropic.MessageNewParams{}, err
+		}
+		params.Tools = append(params.Tools, anthropic.ToolUnionParam{OfTool: toolParam})
+	}
+
+	if opts.Temperature != nil {
+		params.Temperature = anthropic.Float(*opts.Temperature)
+	}
+
+	if opts.TopP != nil {
+		params.TopP = anthropic.Float(*opts.TopP)
+	}
+
+	// Claude has no native JSON mode, so JSON output is requested through
+	// the system prompt.
+	systemPrompt := util.WithJSONInstruction(message.SystemPrompt, opts)
+	if systemPrompt != "" {
+		params.System = []anthropic.TextBlockParam{{Text: systemPrompt}}
+	}
+
+	return params, nil
+}
+
+// userBlocks returns the content blocks of a user turn: the images of
+// message, loaded and preprocessed, followed by text.
+func (c *Client) userBlocks(ctx context.Context, text string, message models.AIChatMessage) ([]anthropic.ContentBlockParamUnion, error) {
+	var blocks []anthropic.ContentBlockParamUnion
+
+	images, err := util.LoadImagesWith(ctx, message, c.imageResolver)
+	if err != nil {
+		return nil, err
+	}
+	if c.convertHEIC {
+		if images, err = util.ConvertHEICImages(images, c.jpegQuality); err != nil {
+			return nil, err
+		}
+	}
+	images = util.PreprocessImages(images, c.maxImageDim, c.jpegQuality)
+	for _, image := range images {
+		blocks = append(blocks, anthropic.NewImageBlockBase64(image.ContentType(), base64.StdEncoding.EncodeToString(image.Bytes)))
+	}
+
+	if text != "" {
+		blocks = append(blocks, anthropic.NewTextBlock(text))
+	}
+	return blocks, nil
+}
+
+// toToolParam converts a tool definition, splitting its JSON Schema into
+// the properties/required fields the SDK models explicitly.
+func toToolParam(tool models.Tool) (*anthropic.ToolParam, error) {
+	var schema map[string]any
+	if len(tool.Parameters) > 0 {
+		if err := json.Unmarshal(tool.Parameters, &schema); err != nil {
+			return nil, fmt.Errorf("invalid parameters schema for tool %q: %w", tool.Name, err)
+		}
+	}
+
+	inputSchema := anthropic.ToolInputSchemaParam{ExtraFields: map[string]any{}}
+	for key, value := range schema {
+		switch key {
+		case "type":
+		case "properties":
+			inputSchema.Properties = value
+		case "required":
+			names, _ := value.([]any)
+			for _, name := range names {
+				if s, ok := name.(string); ok {
+					inputSchema.Required = append(inputSchema.Required, s)
+				}
+			}
+		default:
+			inputSchema.ExtraFields[key] = value
+		}
+	}
+
+	toolParam := &anthropic.ToolParam{
+		Name:        tool.Name,
+		InputSchema: inputSchema,
+	}
+	if tool.Description != "" {
+		toolParam.Description = anthropic.String(tool.Description)
+	}
+
+	return toolParam, nil
+}
+
+// finishReason normalizes an Anthropic stop_reason.
+func finishReason(reason string) models.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "end_turn", "stop_sequence":
+		return models.FinishReasonStop
+	case "max_tokens":
+		return models.FinishReasonLength
+	case "tool_use":
+		return models.FinishReasonToolCalls
+	case "refusal":
+		return models.FinishReasonContentFilter
+	}
+	return models.FinishReasonOther
+}
+
+// wrapError attaches the HTTP status of an Anthropic API error so callers can
+// classify it without depending on the anthropic package.
+func wrapError(err error) error {
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		return models.NewAIError("anthropic", apiErr.StatusCode, err)
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+func TestGenerateRefusal(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"id": "msg_1",
+			"type": "message",
+			"role": "assistant",
+			"model": "claude-sonnet-4-5",
+			"content": [],
+			"stop_reason": "refusal",
+			"usage": {"input_tokens": 10, "output_tokens": 0}
+		}`)
+	}))
+	defer srv.Close()
+	t.Setenv("ANTHROPIC_BASE_URL", srv.URL)
+
+	client, err := NewClient("test-key", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	result, err := client.GenerateWithTools(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{})
+
+	var aiErr *models.AIError
+	if !errors.As(err, &aiErr) {
+		t.Fatalf("GenerateWithTools error = %v, want a *models.AIError", err)
+	}
+	if aiErr.Kind != models.ErrorKindContentFiltered {
+		t.Errorf("Kind = %q, want %q", aiErr.Kind, models.ErrorKindContentFiltered)
+	}
+	if aiErr.Reason != "refusal" {
+		t.Errorf("Reason = %q, want %q", aiErr.Reason, "refusal")
+	}
+	if result.FinishReason != models.FinishReasonContentFilter {
+		t.Errorf("FinishReason = %q, want %q", result.FinishReason, models.FinishReasonContentFilter)
+	}
+}
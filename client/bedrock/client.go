@@ -0,0 +1,659 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+	"github.com/A-pen-app/ai-client/util"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// maxImages is the default AIClientOptions.MaxImages, the most images
+// Claude on Bedrock accepts in one request.
+const maxImages = 20
+
+const (
+	anthropicVersion = "bedrock-2023-05-31"
+
+	// defaultMaxTokens is used when the caller does not set MaxTokens, since
+	// the Anthropic request body requires an explicit output budget.
+	defaultMaxTokens = 4096
+
+	// maxTemperature is the upper bound Anthropic and Titan models accept for
+	// temperature.
+	maxTemperature = 1.0
+)
+
+// Client wraps the Bedrock runtime API and implements the AIClient interface
+// for Anthropic (Claude) and Amazon Titan text models hosted on Bedrock.
+type Client struct {
+	client         *bedrockruntime.Client
+	defaultModelID string
+	// maxImageDim and jpegQuality configure image preprocessing; a zero
+	// maxImageDim disables it.
+	maxImageDim int
+	jpegQuality int
+	// convertHEIC converts HEIC images to JPEG before they are attached.
+	convertHEIC bool
+	// imageResolver fetches ImageUrls; nil uses util.HTTPImageResolver.
+	imageResolver util.ImageResolver
+	closed        atomic.Bool
+}
+
+// NewClient creates a new Bedrock runtime client. Credentials are resolved
+// through the default AWS credential chain.
+func NewClient(region string, modelID string, opts ...Option) (store.AIClient, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var loadOpts []func(*config.LoadOptions) error
+	if region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background(), loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("bedrock region cannot be empty")
+	}
+
+	if modelID == "" {
+		modelID = "anthropic.claude-3-5-sonnet-20240620-v1:0"
+	}
+
+	return &Client{
+		client:         bedrockruntime.NewFromConfig(cfg),
+		defaultModelID: modelID,
+		maxImageDim:    o.maxImageDim,
+		jpegQuality:    o.jpegQuality,
+		convertHEIC:    o.convertHEIC,
+		imageResolver:  o.imageResolver,
+	}, nil
+}
+
+type imageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type contentBlock struct {
+	Type   string          `json:"type"`
+	Text   string          `json:"text,omitempty"`
+	Source *imageSource    `json:"source,omitempty"`
+	ID     string          `json:"id,omitempty"`
+	Name   string          `json:"name,omitempty"`
+	Input  json.RawMessage `json:"input,omitempty"`
+}
+
+type toolDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+type invokeRequest struct {
+	AnthropicVersion string           `json:"anthropic_version"`
+	MaxTokens        int64            `json:"max_tokens"`
+	System           string           `json:"system,omitempty"`
+	Messages         []message        `json:"messages"`
+	Temperature      *float64         `json:"temperature,omitempty"`
+	TopP             *float64         `json:"top_p,omitempty"`
+	Tools            []toolDefinition `json:"tools,omitempty"`
+}
+
+type invokeResponse struct {
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int64 `json:"input_tokens"`
+		OutputTokens int64 `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+type streamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+}
+
+func (c *Client) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	text, _, err := c.GenerateWithUsage(ctx, message, opts)
+	return text, err
+}
+
+// GenerateChat replies to an ordered conversation, mapped onto a message
+// with models.NewChatMessage.
+func (c *Client) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+// GenerateWithUsage behaves like Generate and also reports token usage from
+// the response body.
+func (c *Client) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	return result.Text, result.Usage, err
+}
+
+// GenerateWithTools returns the full result, including any tool_use blocks
+// the model produced for opts.Tools.
+func (c *Client) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	ctx, cancel := util.WithRequestTimeout(ctx, opts.RequestTimeout)
+	defer cancel()
+
+	result, err := c.generate(ctx, message, opts)
+	return result, util.TimeoutError(ctx, err)
+}
+
+func (c *Client) generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	if c.client == nil {
+		return models.GenerateResult{}, fmt.Errorf("bedrock client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return models.GenerateResult{}, models.ErrClientClosed
+	}
+
+	modelID, body, err := c.buildRequest(ctx, message, opts)
+	if err != nil {
+		return models.GenerateResult{}, err
+	}
+
+	resp, err := c.client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(modelID),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+		Body:        body,
+	})
+	if err != nil {
+		return models.GenerateResult{}, wrapError(fmt.Errorf("failed to invoke model: %w", err))
+	}
+
+	if isTitanModel(modelID) {
+		return titanResult(resp.Body, opts)
+	}
+
+	var invokeResp invokeResponse
+	if err := json.Unmarshal(resp.Body, &invokeResp); err != nil {
+		return models.GenerateResult{}, fmt.Errorf("failed to decode bedrock response: %w", err)
+	}
+
+	result := models.GenerateResult{
+		Usage: models.Usage{
+			PromptTokens:     invokeResp.Usage.InputTokens,
+			CompletionTokens: invokeResp.Usage.OutputTokens,
+			TotalTokens:      invokeResp.Usage.InputTokens + invokeResp.Usage.OutputTokens,
+		},
+		FinishReason: finishReason(invokeResp.StopReason),
+	}
+	if opts.IncludeRawResponse {
+		result.Raw = resp.Body
+	}
+	if result.FinishReason == models.FinishReasonContentFilter {
+		return result, models.NewContentFilteredError("bedrock", invokeResp.StopReason)
+	}
+
+	if len(invokeResp.Content) == 0 {
+		return result, &models.AIError{Kind: models.ErrorKindEmptyResponse, Provider: "bedrock", Err: errors.New("empty response content from Bedrock")}
+	}
+
+	var resultText strings.Builder
+	resultText.WriteString(message.AssistantPrefill)
+	for _, block := range invokeResp.Content {
+		switch block.Type {
+		case "text":
+			resultText.WriteString(block.Text)
+		case "tool_use":
+			result.ToolCalls = append(result.ToolCalls, models.ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: block.Input,
+			})
+		}
+	}
+	result.Text = resultText.String()
+
+	return result, nil
+}
+
+// GenerateN returns every candidate requested with opts.N. With N <= 1 it
+// returns the single Generate result.
+func (c *Client) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Texts(), nil
+}
+
+// StreamGenerate streams the response text as it is produced. The returned
+// channel is closed once the stream ends; the last chunk has Done set and
+// carries any provider or context error.
+func (c *Client) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	ctx, cancel := util.WithRequestTimeout(ctx, opts.RequestTimeout)
+
+	ch, err := c.stream(ctx, message, opts, cancel)
+	if err != nil {
+		cancel()
+		return nil, util.TimeoutError(ctx, err)
+	}
+	return ch, nil
+}
+
+// stream starts the provider stream. On success the producing goroutine
+// owns cancel and calls it when the stream ends.
+func (c *Client) stream(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions, cancel context.CancelFunc) (<-chan models.StreamChunk, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("bedrock client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return nil, models.ErrClientClosed
+	}
+
+	modelID, body, err := c.buildRequest(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.InvokeModelWithResponseStream(ctx, &bedrockruntime.InvokeModelWithResponseStreamInput{
+		ModelId:     aws.String(modelID),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+		Body:        body,
+	})
+	if err != nil {
+		return nil, wrapError(fmt.Errorf("failed to invoke model: %w", err))
+	}
+
+	stream := resp.GetStream()
+	titan := isTitanModel(modelID)
+
+	ch := make(chan models.StreamChunk)
+	go func() {
+		defer close(ch)
+		defer cancel()
+		defer stream.Close()
+
+		if message.AssistantPrefill != "" && !util.SendChunk(ctx, ch, models.StreamChunk{Text: message.AssistantPrefill}) {
+			util.FinishStream(ctx, ch, nil)
+			return
+		}
+
+		for {
+			var event types.ResponseStream
+			var ok bool
+			select {
+			case event, ok = <-stream.Events():
+			case <-ctx.Done():
+				util.FinishStream(ctx, ch, nil)
+				return
+			}
+			if !ok {
+				break
+			}
+
+			chunk, isChunk := event.(*types.ResponseStreamMemberChunk)
+			if !isChunk {
+				continue
+			}
+
+			text, err := streamText(chunk.Value.Bytes, titan)
+			if err != nil {
+				util.FinishStream(ctx, ch, err)
+				return
+			}
+			if text == "" {
+				continue
+			}
+			if !util.SendChunk(ctx, ch, models.StreamChunk{Text: text}) {
+				util.FinishStream(ctx, ch, nil)
+				return
+			}
+		}
+
+		util.FinishStream(ctx, ch, wrapError(stream.Err()))
+	}()
+
+	return ch, nil
+}
+
+// streamText returns the text delta of a stream chunk, or "" for chunks
+// that carry none.
+func streamText(data []byte, titan bool) (string, error) {
+	if titan {
+		var payload titanStreamEvent
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return "", fmt.Errorf("failed to decode bedrock stream: %w", err)
+		}
+		return payload.OutputText, nil
+	}
+
+	var payload streamEvent
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return "", fmt.Errorf("failed to decode bedrock stream: %w", err)
+	}
+	if payload.Type != "content_block_delta" || payload.Delta.Type != "text_delta" {
+		return "", nil
+	}
+	return payload.Delta.Text, nil
+}
+
+// RawRequest is an InvokeModel request built by the caller for GenerateRaw.
+type RawRequest struct {
+	// ModelID defaults to the client's default model.
+	ModelID string
+	// Body is the model-specific JSON request body.
+	Body []byte
+}
+
+// BuildRequest returns the RawRequest, model ID and JSON body, that
+// Generate would invoke for message and opts, with images downloaded and
+// preprocessed as configured, without calling the API. The result can be
+// passed to GenerateRaw.
+func (c *Client) BuildRequest(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (any, error) {
+	modelID, body, err := c.buildRequest(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return RawRequest{ModelID: modelID, Body: body}, nil
+}
+
+// GenerateRaw invokes a model with a request body built by the caller,
+// bypassing the AIChatMessage mapping, and returns the text blocks of an
+// Anthropic Messages reply, or the output text of a Titan reply. params
+// must be a RawRequest (or a pointer to one). This is an escape hatch
+// specific to this client.
+func (c *Client) GenerateRaw(ctx context.Context, params any) (string, error) {
+	if c.client == nil {
+		return "", fmt.Errorf("bedrock client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return "", models.ErrClientClosed
+	}
+
+	var request RawRequest
+	switch p := params.(type) {
+	case RawRequest:
+		request = p
+	case *RawRequest:
+		request = *p
+	default:
+		return "", fmt.Errorf("bedrock GenerateRaw expects bedrock.RawRequest, got %T", params)
+	}
+	if request.ModelID == "" {
+		request.ModelID = c.defaultModelID
+	}
+
+	resp, err := c.client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(request.ModelID),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+		Body:        request.Body,
+	})
+	if err != nil {
+		return "", wrapError(fmt.Errorf("failed to invoke model: %w", err))
+	}
+
+	if isTitanModel(request.ModelID) {
+		result, err := titanResult(resp.Body, models.AIClientOptions{})
+		return result.Text, err
+	}
+
+	var invokeResp invokeResponse
+	if err := json.Unmarshal(resp.Body, &invokeResp); err != nil {
+		return "", fmt.Errorf("failed to decode bedrock response: %w", err)
+	}
+
+	if finishReason(invokeResp.StopReason) == models.FinishReasonContentFilter {
+		return "", models.NewContentFilteredError("bedrock", invokeResp.StopReason)
+	}
+
+	var text strings.Builder
+	for _, block := range invokeResp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String(), nil
+}
+
+// HealthCheck generates a single token with the default model, as the
+// Bedrock runtime API has no cheaper authenticated call.
+func (c *Client) HealthCheck(ctx context.Context) error {
+	_, err := c.generate(ctx, models.AIChatMessage{Text: "ping"}, models.AIClientOptions{MaxTokens: 1})
+	if models.KindOf(err) == models.ErrorKindEmptyResponse {
+		// The provider answered; one token may not produce any text.
+		return nil
+	}
+	return err
+}
+
+// Close makes later calls fail with models.ErrClientClosed. The Bedrock
+// client holds no resources that need releasing. It is safe to call more
+// than once.
+func (c *Client) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// buildRequest maps an AIChatMessage and options onto the request body of
+// the model family, Anthropic Messages or Titan text, and returns the model
+// ID to invoke.
+func (c *Client) buildRequest(ctx context.Context, msg models.AIChatMessage, opts models.AIClientOptions) (string, []byte, error) {
+	opts = util.AdaptMaxTokens(ctx, opts)
+
+	if err := opts.ValidateSampling(maxTemperature); err != nil {
+		return "", nil, err
+	}
+
+	if err := opts.ValidateResponseFormat(); err != nil {
+		return "", nil, err
+	}
+
+	if opts.Seed != nil {
+		return "", nil, fmt.Errorf("seed is not supported by bedrock")
+	}
+
+	if opts.FrequencyPenalty != nil || opts.PresencePenalty != nil {
+		return "", nil, fmt.Errorf("frequency and presence penalties are not supported by bedrock")
+	}
+
+	if opts.N > 1 {
+		return "", nil, fmt.Errorf("bedrock supports a single candidate, requested %d", opts.N)
+	}
+
+	if err := msg.ValidateHistory(); err != nil {
+		return "", nil, err
+	}
+
+	if err := opts.ValidateImageCount("bedrock", msg, maxImages); err != nil {
+		return "", nil, err
+	}
+
+	if msg.HasAudio() {
+		return "", nil, fmt.Errorf("audio input is not supported by bedrock")
+	}
+
+	if len(msg.DocumentUrls) > 0 {
+		return "", nil, fmt.Errorf("document input is not supported by bedrock")
+	}
+
+	if err := opts.ValidateModel("bedrock"); err != nil {
+		return "", nil, err
+	}
+
+	msg = util.FoldSystemPrompt(msg, opts)
+
+	modelID := c.defaultModelID
+	if opts.Model != "" {
+		modelID = opts.Model
+	}
+
+	if err := opts.UnsupportedReasoning("bedrock", modelID); err != nil {
+		return "", nil, err
+	}
+
+	if err := opts.UnsupportedLogitBias("bedrock"); err != nil {
+		return "", nil, err
+	}
+
+	if opts.LogProbs {
+		return "", nil, fmt.Errorf("logprobs are not supported by bedrock")
+	}
+
+	if isTitanModel(modelID) {
+		body, err := buildTitanRequest(msg, opts, modelID)
+		if err != nil {
+			return "", nil, err
+		}
+		return modelID, body, nil
+	}
+
+	maxTokens := opts.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+
+	blocks, err := c.userBlocks(ctx, msg.Text, msg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	messages := make([]message, 0, len(msg.History)+2)
+	for _, turn := range msg.History {
+		switch turn.Role {
+		case models.RoleUser, models.RoleAssistant:
+			content := []contentBlock{{Type: "text", Text: turn.Text}}
+			if turn.HasImages() {
+				if content, err = c.userBlocks(ctx, turn.Text, turn.ImageMessage(msg.ImageMimeType)); err != nil {
+					return "", nil, err
+				}
+			}
+			messages = append(messages, message{
+				Role:    string(turn.Role),
+				Content: content,
+			})
+		default:
+			return "", nil, fmt.Errorf("unsupported turn role %q", turn.Role)
+		}
+	}
+	messages = append(messages, message{Role: "user", Content: blocks})
+	if msg.AssistantPrefill != "" {
+		messages = append(messages, message{
+			Role:    "assistant",
+			Content: []contentBlock{{Type: "text", Text: msg.AssistantPrefill}},
+		})
+	}
+
+	req := invokeRequest{
+		AnthropicVersion: anthropicVersion,
+		MaxTokens:        maxTokens,
+		System:           util.WithJSONInstruction(msg.SystemPrompt, opts),
+		Messages:         messages,
+		Temperature:      opts.Temperature,
+		TopP:             opts.TopP,
+	}
+
+	for _, tool := range opts.Tools {
+		req.Tools = append(req.Tools, toolDefinition{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.Parameters,
+		})
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode bedrock request: %w", err)
+	}
+
+	return modelID, body, nil
+}
+
+// userBlocks returns the content blocks of a user turn: the images of msg,
+// loaded and preprocessed, followed by text.
+func (c *Client) userBlocks(ctx context.Context, text string, msg models.AIChatMessage) ([]contentBlock, error) {
+	var blocks []contentBlock
+
+	images, err := util.LoadImagesWith(ctx, msg, c.imageResolver)
+	if err != nil {
+		return nil, err
+	}
+	if c.convertHEIC {
+		if images, err = util.ConvertHEICImages(images, c.jpegQuality); err != nil {
+			return nil, err
+		}
+	}
+	images = util.PreprocessImages(images, c.maxImageDim, c.jpegQuality)
+	for _, image := range images {
+		blocks = append(blocks, contentBlock{
+			Type: "image",
+			Source: &imageSource{
+				Type:      "base64",
+				MediaType: image.ContentType(),
+				Data:      base64.StdEncoding.EncodeToString(image.Bytes),
+			},
+		})
+	}
+
+	if text != "" {
+		blocks = append(blocks, contentBlock{Type: "text", Text: text})
+	}
+	return blocks, nil
+}
+
+// finishReason normalizes the stop_reason of an Anthropic model on Bedrock.
+func finishReason(reason string) models.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "end_turn", "stop_sequence":
+		return models.FinishReasonStop
+	case "max_tokens":
+		return models.FinishReasonLength
+	case "tool_use":
+		return models.FinishReasonToolCalls
+	case "refusal":
+		return models.FinishReasonContentFilter
+	}
+	return models.FinishReasonOther
+}
+
+// wrapError attaches the HTTP status of a Bedrock API error so callers can
+// classify it without depending on the AWS SDK.
+func wrapError(err error) error {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return models.NewAIError("bedrock", respErr.HTTPStatusCode(), err)
+	}
+	return err
+}
@@ -0,0 +1,215 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// newTestClient returns a Client for modelID whose InvokeModel calls go to
+// a server answering with response.
+func newTestClient(t *testing.T, modelID string, response string) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(srv.Close)
+
+	return &Client{
+		client: bedrockruntime.New(bedrockruntime.Options{
+			Region:       "us-east-1",
+			BaseEndpoint: aws.String(srv.URL),
+			Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{AccessKeyID: "test", SecretAccessKey: "test"}, nil
+			}),
+		}),
+		defaultModelID: modelID,
+	}
+}
+
+func TestGenerateRefusal(t *testing.T) {
+	client := newTestClient(t, "anthropic.claude-3-5-sonnet-20240620-v1:0", `{
+		"id": "msg_1",
+		"type": "message",
+		"role": "assistant",
+		"content": [],
+		"stop_reason": "refusal",
+		"usage": {"input_tokens": 10, "output_tokens": 0}
+	}`)
+
+	result, err := client.GenerateWithTools(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{})
+
+	var aiErr *models.AIError
+	if !errors.As(err, &aiErr) {
+		t.Fatalf("GenerateWithTools error = %v, want a *models.AIError", err)
+	}
+	if aiErr.Kind != models.ErrorKindContentFiltered {
+		t.Errorf("Kind = %q, want %q", aiErr.Kind, models.ErrorKindContentFiltered)
+	}
+	if aiErr.Reason != "refusal" {
+		t.Errorf("Reason = %q, want %q", aiErr.Reason, "refusal")
+	}
+	if result.FinishReason != models.FinishReasonContentFilter {
+		t.Errorf("FinishReason = %q, want %q", result.FinishReason, models.FinishReasonContentFilter)
+	}
+}
+
+func TestBuildRequestTitan(t *testing.T) {
+	client := newTestClient(t, "amazon.titan-text-express-v1", "{}")
+
+	temperature, topP := 0.2, 0.9
+	built, err := client.BuildRequest(context.Background(), models.AIChatMessage{
+		SystemPrompt: "You read name cards.",
+		Text:         "Read this card.",
+	}, models.AIClientOptions{MaxTokens: 256, Temperature: &temperature, TopP: &topP})
+	if err != nil {
+		t.Fatalf("BuildRequest: %v", err)
+	}
+	request := built.(RawRequest)
+	if request.ModelID != "amazon.titan-text-express-v1" {
+		t.Errorf("ModelID = %q, want the Titan model", request.ModelID)
+	}
+
+	var body struct {
+		InputText            string `json:"inputText"`
+		TextGenerationConfig struct {
+			MaxTokenCount int64    `json:"maxTokenCount"`
+			Temperature   *float64 `json:"temperature"`
+			TopP          *float64 `json:"topP"`
+		} `json:"textGenerationConfig"`
+		Messages json.RawMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(request.Body, &body); err != nil {
+		t.Fatalf("decoding request body: %v", err)
+	}
+	if want := "You read name cards.\n\nRead this card."; body.InputText != want {
+		t.Errorf("inputText = %q, want %q", body.InputText, want)
+	}
+	config := body.TextGenerationConfig
+	if config.MaxTokenCount != 256 {
+		t.Errorf("maxTokenCount = %d, want 256", config.MaxTokenCount)
+	}
+	if config.Temperature == nil || *config.Temperature != temperature {
+		t.Errorf("temperature = %v, want %v", config.Temperature, temperature)
+	}
+	if config.TopP == nil || *config.TopP != topP {
+		t.Errorf("topP = %v, want %v", config.TopP, topP)
+	}
+	if body.Messages != nil {
+		t.Errorf("Titan request has Anthropic messages: %s", body.Messages)
+	}
+}
+
+func TestBuildRequestTitanHistory(t *testing.T) {
+	client := newTestClient(t, "amazon.titan-text-express-v1", "{}")
+
+	built, err := client.BuildRequest(context.Background(), models.AIChatMessage{
+		Text: "And the title?",
+		History: []models.Turn{
+			{Role: models.RoleUser, Text: "What is the name?"},
+			{Role: models.RoleAssistant, Text: "Alice"},
+		},
+	}, models.AIClientOptions{})
+	if err != nil {
+		t.Fatalf("BuildRequest: %v", err)
+	}
+
+	var body titanRequest
+	if err := json.Unmarshal(built.(RawRequest).Body, &body); err != nil {
+		t.Fatalf("decoding request body: %v", err)
+	}
+	if want := "User: What is the name?\nBot: Alice\nUser: And the title?\nBot:"; body.InputText != want {
+		t.Errorf("inputText = %q, want %q", body.InputText, want)
+	}
+}
+
+func TestBuildRequestTitanUnsupported(t *testing.T) {
+	client := newTestClient(t, "amazon.titan-text-express-v1", "{}")
+
+	tests := []struct {
+		name    string
+		message models.AIChatMessage
+		opts    models.AIClientOptions
+	}{
+		{
+			name:    "images",
+			message: models.AIChatMessage{Text: "Read this card.", Images: []models.ImageData{{Bytes: []byte("\x89PNG\r\n\x1a\n"), MimeType: "image/png"}}},
+		},
+		{
+			name:    "tools",
+			message: models.AIChatMessage{Text: "Read this card."},
+			opts:    models.AIClientOptions{Tools: []models.Tool{{Name: "lookup", Parameters: json.RawMessage(`{"type": "object"}`)}}},
+		},
+		{
+			name:    "assistant prefill",
+			message: models.AIChatMessage{Text: "Read this card.", AssistantPrefill: "{"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.BuildRequest(context.Background(), tt.message, tt.opts); err == nil {
+				t.Error("BuildRequest succeeded, want an unsupported error")
+			}
+		})
+	}
+}
+
+func TestGenerateTitan(t *testing.T) {
+	client := newTestClient(t, "amazon.titan-text-express-v1", `{
+		"inputTextTokenCount": 12,
+		"results": [{
+			"tokenCount": 5,
+			"outputText": "{\"name\": \"Alice\"}",
+			"completionReason": "FINISH"
+		}]
+	}`)
+
+	result, err := client.GenerateWithTools(context.Background(), models.AIChatMessage{Text: "Read this card."}, models.AIClientOptions{})
+	if err != nil {
+		t.Fatalf("GenerateWithTools: %v", err)
+	}
+	if want := `{"name": "Alice"}`; result.Text != want {
+		t.Errorf("Text = %q, want %q", result.Text, want)
+	}
+	if want := (models.Usage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}); result.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", result.Usage, want)
+	}
+	if result.FinishReason != models.FinishReasonStop {
+		t.Errorf("FinishReason = %q, want %q", result.FinishReason, models.FinishReasonStop)
+	}
+}
+
+func TestGenerateTitanContentFiltered(t *testing.T) {
+	client := newTestClient(t, "amazon.titan-text-express-v1", `{
+		"inputTextTokenCount": 12,
+		"results": [{"tokenCount": 0, "outputText": "", "completionReason": "CONTENT_FILTERED"}]
+	}`)
+
+	_, err := client.Generate(context.Background(), models.AIChatMessage{Text: "Read this card."}, models.AIClientOptions{})
+
+	var aiErr *models.AIError
+	if !errors.As(err, &aiErr) || aiErr.Kind != models.ErrorKindContentFiltered {
+		t.Fatalf("Generate error = %v, want a content-filtered error", err)
+	}
+	if aiErr.Reason != "CONTENT_FILTERED" {
+		t.Errorf("Reason = %q, want %q", aiErr.Reason, "CONTENT_FILTERED")
+	}
+}
+
+func TestTitanModelsAreKnown(t *testing.T) {
+	for _, model := range []string{"amazon.titan-text-express-v1", "amazon.titan-text-lite-v1", "amazon.titan-text-premier-v1:0"} {
+		if err := (models.AIClientOptions{Model: model}).ValidateModel("bedrock"); err != nil {
+			t.Errorf("ValidateModel(%q): %v", model, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package bedrock
+
+import "github.com/A-pen-app/ai-client/util"
+
+// Option configures a Client created by NewClient.
+type Option func(*options)
+
+type options struct {
+	maxImageDim   int
+	jpegQuality   int
+	convertHEIC   bool
+	imageResolver util.ImageResolver
+}
+
+// WithImagePreprocessing downscales images whose longer side exceeds maxDim
+// pixels and re-encodes them as JPEG at jpegQuality (1-100, default 85)
+// before they are attached, see util.PreprocessImage. High-resolution photos
+// then cost fewer tokens with no loss in OCR accuracy.
+func WithImagePreprocessing(maxDim int, jpegQuality int) Option {
+	return func(o *options) {
+		o.maxImageDim = maxDim
+		o.jpegQuality = jpegQuality
+	}
+}
+
+// WithHEICConversion converts HEIC images, such as iPhone photos, to JPEG
+// before they are attached, since most providers reject HEIC. It needs a
+// binary built with -tags heic; otherwise requests with HEIC images fail
+// with util.ErrHEICUnsupported. The JPEG quality is the one set with
+// WithImagePreprocessing, or 85.
+func WithHEICConversion() Option {
+	return func(o *options) {
+		o.convertHEIC = true
+	}
+}
+
+// WithImageResolver fetches ImageUrls with resolver instead of
+// util.HTTPImageResolver, e.g. to read object-store keys or sign URLs
+// before downloading them.
+func WithImageResolver(resolver util.ImageResolver) Option {
+	return func(o *options) {
+		o.imageResolver = resolver
+	}
+}
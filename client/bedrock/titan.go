@@ -0,0 +1,149 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/util"
+)
+
+// titanRequest is the InvokeModel body of an Amazon Titan text model.
+type titanRequest struct {
+	InputText            string                `json:"inputText"`
+	TextGenerationConfig titanGenerationConfig `json:"textGenerationConfig"`
+}
+
+type titanGenerationConfig struct {
+	// MaxTokenCount is omitted when unset, keeping the model's default.
+	MaxTokenCount int64    `json:"maxTokenCount,omitempty"`
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"topP,omitempty"`
+}
+
+type titanResponse struct {
+	InputTextTokenCount int64 `json:"inputTextTokenCount"`
+	Results             []struct {
+		TokenCount       int64  `json:"tokenCount"`
+		OutputText       string `json:"outputText"`
+		CompletionReason string `json:"completionReason"`
+	} `json:"results"`
+}
+
+type titanStreamEvent struct {
+	OutputText string `json:"outputText"`
+}
+
+// isTitanModel reports whether modelID is an Amazon Titan model, which takes
+// a Titan text body instead of the Anthropic Messages format.
+func isTitanModel(modelID string) bool {
+	return strings.HasPrefix(modelID, "amazon.titan-")
+}
+
+// buildTitanRequest maps msg and opts onto a Titan text request body. Titan
+// text models take a single prompt and no images, tools or prefill.
+func buildTitanRequest(msg models.AIChatMessage, opts models.AIClientOptions, modelID string) ([]byte, error) {
+	if msg.ImageCount() > 0 {
+		return nil, fmt.Errorf("image input is not supported by bedrock model %s", modelID)
+	}
+
+	if len(opts.Tools) > 0 {
+		return nil, fmt.Errorf("tools are not supported by bedrock model %s", modelID)
+	}
+
+	if msg.AssistantPrefill != "" {
+		return nil, fmt.Errorf("assistant prefill is not supported by bedrock model %s", modelID)
+	}
+
+	req := titanRequest{
+		InputText: titanPrompt(util.WithJSONInstruction(msg.SystemPrompt, opts), msg),
+		TextGenerationConfig: titanGenerationConfig{
+			MaxTokenCount: opts.MaxTokens,
+			Temperature:   opts.Temperature,
+			TopP:          opts.TopP,
+		},
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode bedrock request: %w", err)
+	}
+	return body, nil
+}
+
+// titanPrompt writes the system prompt, History and message text into one
+// prompt. History is written as the "User:" and "Bot:" turns Titan
+// expects for conversations.
+func titanPrompt(systemPrompt string, msg models.AIChatMessage) string {
+	var prompt strings.Builder
+	if systemPrompt != "" {
+		prompt.WriteString(systemPrompt)
+		prompt.WriteString("\n\n")
+	}
+
+	if len(msg.History) == 0 {
+		prompt.WriteString(msg.Text)
+		return prompt.String()
+	}
+
+	for _, turn := range msg.History {
+		if turn.Role == models.RoleAssistant {
+			prompt.WriteString("Bot: ")
+		} else {
+			prompt.WriteString("User: ")
+		}
+		prompt.WriteString(turn.Text)
+		prompt.WriteString("\n")
+	}
+	prompt.WriteString("User: ")
+	prompt.WriteString(msg.Text)
+	prompt.WriteString("\nBot:")
+	return prompt.String()
+}
+
+// titanResult decodes a Titan text response body.
+func titanResult(body []byte, opts models.AIClientOptions) (models.GenerateResult, error) {
+	var resp titanResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return models.GenerateResult{}, fmt.Errorf("failed to decode bedrock response: %w", err)
+	}
+
+	result := models.GenerateResult{
+		Usage: models.Usage{PromptTokens: resp.InputTextTokenCount},
+	}
+	if opts.IncludeRawResponse {
+		result.Raw = body
+	}
+
+	if len(resp.Results) == 0 {
+		return result, &models.AIError{Kind: models.ErrorKindEmptyResponse, Provider: "bedrock", Err: errors.New("empty response results from Bedrock")}
+	}
+
+	output := resp.Results[0]
+	result.Usage.CompletionTokens = output.TokenCount
+	result.Usage.TotalTokens = resp.InputTextTokenCount + output.TokenCount
+	result.FinishReason = titanFinishReason(output.CompletionReason)
+	if result.FinishReason == models.FinishReasonContentFilter {
+		return result, models.NewContentFilteredError("bedrock", output.CompletionReason)
+	}
+
+	result.Text = output.OutputText
+	return result, nil
+}
+
+// titanFinishReason normalizes the completionReason of a Titan model.
+func titanFinishReason(reason string) models.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "FINISH", "STOP_CRITERIA_MET":
+		return models.FinishReasonStop
+	case "LENGTH":
+		return models.FinishReasonLength
+	case "CONTENT_FILTERED":
+		return models.FinishReasonContentFilter
+	}
+	return models.FinishReasonOther
+}
@@ -0,0 +1,227 @@
+// Package clienttest provides an in-memory AIClient for testing code built
+// on top of the stores without calling a real provider.
+package clienttest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+)
+
+// Response is a canned reply returned by MockClient. When Err is set the
+// call fails with that error.
+type Response struct {
+	Text      string
+	ToolCalls []models.ToolCall
+	Usage     models.Usage
+	// Candidates is returned by GenerateN instead of Text when set.
+	Candidates   []string
+	FinishReason models.FinishReason
+	Err          error
+}
+
+// Call records the arguments of a single MockClient invocation.
+type Call struct {
+	Message models.AIChatMessage
+	Options models.AIClientOptions
+}
+
+// MockClient implements store.AIClient by replaying queued responses in
+// order and recording every call it receives. It is safe for concurrent use.
+type MockClient struct {
+	mu        sync.Mutex
+	responses []Response
+	calls     []Call
+	closed    bool
+	healthErr error
+}
+
+var (
+	_ store.AIClient    = (*MockClient)(nil)
+	_ store.Transcriber = (*MockClient)(nil)
+)
+
+// NewMockClient creates a MockClient with the given responses queued.
+func NewMockClient(responses ...Response) *MockClient {
+	return &MockClient{responses: responses}
+}
+
+// Enqueue appends responses to the queue.
+func (m *MockClient) Enqueue(responses ...Response) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses = append(m.responses, responses...)
+}
+
+// EnqueueText queues a successful response with the given text.
+func (m *MockClient) EnqueueText(text string) {
+	m.Enqueue(Response{Text: text})
+}
+
+// EnqueueError queues a response that fails with err.
+func (m *MockClient) EnqueueError(err error) {
+	m.Enqueue(Response{Err: err})
+}
+
+// Calls returns a copy of the calls received so far.
+func (m *MockClient) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// CallCount returns the number of calls received so far.
+func (m *MockClient) CallCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.calls)
+}
+
+// LastCall returns the most recent call. It panics if there were no calls.
+func (m *MockClient) LastCall() Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls[len(m.calls)-1]
+}
+
+// AssertCallCount fails t if the number of calls received differs from want.
+func (m *MockClient) AssertCallCount(t testing.TB, want int) {
+	t.Helper()
+	if got := m.CallCount(); got != want {
+		t.Errorf("clienttest: got %d calls, want %d", got, want)
+	}
+}
+
+func (m *MockClient) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	text, _, err := m.GenerateWithUsage(ctx, message, opts)
+	return text, err
+}
+
+// GenerateChat maps turns with models.NewChatMessage and then behaves like
+// Generate, so Calls records the mapped message.
+func (m *MockClient) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return m.Generate(ctx, message, opts)
+}
+
+func (m *MockClient) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	result, err := m.GenerateWithTools(ctx, message, opts)
+	return result.Text, result.Usage, err
+}
+
+func (m *MockClient) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	resp, err := m.next(ctx, message, opts)
+	if err != nil {
+		return models.GenerateResult{}, err
+	}
+	return models.GenerateResult{
+		Text:         resp.Text,
+		ToolCalls:    resp.ToolCalls,
+		Usage:        resp.Usage,
+		Candidates:   resp.Candidates,
+		FinishReason: resp.FinishReason,
+	}, resp.Err
+}
+
+func (m *MockClient) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	result, err := m.GenerateWithTools(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Texts(), nil
+}
+
+// StreamGenerate delivers the queued response text as a single chunk
+// followed by the terminal Done chunk.
+func (m *MockClient) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	resp, err := m.next(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+
+	ch := make(chan models.StreamChunk, 2)
+	if resp.Text != "" {
+		ch <- models.StreamChunk{Text: resp.Text}
+	}
+	ch <- models.StreamChunk{Done: true}
+	close(ch)
+
+	return ch, nil
+}
+
+// SetHealthError makes HealthCheck return err; nil makes it succeed.
+func (m *MockClient) SetHealthError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.healthErr = err
+}
+
+// HealthCheck returns the error set with SetHealthError. It is not recorded
+// as a call and does not consume a queued response.
+func (m *MockClient) HealthCheck(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return models.ErrClientClosed
+	}
+	return m.healthErr
+}
+
+// Close makes later calls fail with models.ErrClientClosed.
+func (m *MockClient) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+// Closed reports whether Close was called.
+func (m *MockClient) Closed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+// Transcribe returns the queued response text. The call is recorded with
+// the clip in Message.Audio.
+func (m *MockClient) Transcribe(ctx context.Context, audio models.AudioData, opts models.AIClientOptions) (string, error) {
+	resp, err := m.next(ctx, models.AIChatMessage{Audio: []models.AudioData{audio}}, opts)
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, resp.Err
+}
+
+// next records the call and pops the next queued response.
+func (m *MockClient) next(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = append(m.calls, Call{Message: message, Options: opts})
+
+	if m.closed {
+		return Response{}, models.ErrClientClosed
+	}
+
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+
+	if len(m.responses) == 0 {
+		return Response{}, fmt.Errorf("clienttest: no response queued for call %d", len(m.calls))
+	}
+
+	resp := m.responses[0]
+	m.responses = m.responses[1:]
+	return resp, nil
+}
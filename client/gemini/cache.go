@@ -0,0 +1,203 @@
+package gemini
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/logging"
+	"google.golang.org/genai"
+)
+
+// DefaultCacheTTL is how long CacheSystemPrompt keeps a cached content
+// when ttl is 0 or less, and the TTL NewPromptCache uses by default.
+const DefaultCacheTTL = time.Hour
+
+// CachedContent is a system prompt stored by Gemini context caching. Pass
+// Name as AIClientOptions.CachedContent.
+type CachedContent struct {
+	Name       string
+	Model      string
+	ExpireTime time.Time
+}
+
+// CacheSystemPrompt stores systemPrompt as a Gemini cached content for
+// model, or the client's default model when model is empty, which expires
+// after ttl. Gemini rejects prompts shorter than the model's minimum for
+// caching, 1024 or more tokens depending on the model.
+func (c *Client) CacheSystemPrompt(ctx context.Context, model string, systemPrompt string, ttl time.Duration) (CachedContent, error) {
+	if c.client == nil {
+		return CachedContent{}, fmt.Errorf("gemini client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return CachedContent{}, models.ErrClientClosed
+	}
+
+	if systemPrompt == "" {
+		return CachedContent{}, fmt.Errorf("system prompt cannot be empty")
+	}
+	if model == "" {
+		model = c.defaultModel
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	cached, err := c.client.Caches.Create(ctx, model, &genai.CreateCachedContentConfig{
+		TTL: ttl,
+		SystemInstruction: &genai.Content{
+			Parts: []*genai.Part{genai.NewPartFromText(systemPrompt)},
+		},
+	})
+	if err != nil {
+		return CachedContent{}, wrapError(fmt.Errorf("failed to create cached content: %w", err))
+	}
+
+	c.cachedPrompts.Store(cached.Name, sha256.Sum256([]byte(systemPrompt)))
+
+	expireTime := cached.ExpireTime
+	if expireTime.IsZero() {
+		expireTime = time.Now().Add(ttl)
+	}
+	return CachedContent{Name: cached.Name, Model: model, ExpireTime: expireTime}, nil
+}
+
+// ExtendCachedContent moves the expiry of the cached content name to ttl
+// from now.
+func (c *Client) ExtendCachedContent(ctx context.Context, name string, ttl time.Duration) (time.Time, error) {
+	if c.closed.Load() {
+		return time.Time{}, models.ErrClientClosed
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	cached, err := c.client.Caches.Update(ctx, name, &genai.UpdateCachedContentConfig{TTL: ttl})
+	if err != nil {
+		return time.Time{}, wrapError(fmt.Errorf("failed to update cached content: %w", err))
+	}
+	if cached.ExpireTime.IsZero() {
+		return time.Now().Add(ttl), nil
+	}
+	return cached.ExpireTime, nil
+}
+
+// DeleteCachedContent deletes the cached content name, which stops its
+// storage billing.
+func (c *Client) DeleteCachedContent(ctx context.Context, name string) error {
+	if c.closed.Load() {
+		return models.ErrClientClosed
+	}
+
+	if _, err := c.client.Caches.Delete(ctx, name, nil); err != nil {
+		return wrapError(fmt.Errorf("failed to delete cached content: %w", err))
+	}
+	c.cachedPrompts.Delete(name)
+	return nil
+}
+
+// cacheRenewMargin is how long before expiry PromptCache extends a cached
+// content, so that calls in flight do not hit an expired cache.
+const cacheRenewMargin = 5 * time.Minute
+
+// PromptCache creates cached contents for system prompts on first use and
+// keeps them alive while they are used. It is safe for concurrent use.
+type PromptCache struct {
+	client *Client
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]CachedContent
+}
+
+// NewPromptCache returns a PromptCache whose cached contents live for ttl
+// (default: DefaultCacheTTL) after their last renewal.
+func NewPromptCache(client *Client, ttl time.Duration) *PromptCache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &PromptCache{
+		client:  client,
+		ttl:     ttl,
+		entries: make(map[[sha256.Size]byte]CachedContent),
+	}
+}
+
+// Name returns the cached content holding systemPrompt for model, creating
+// it or extending its expiry when needed. It returns "" when caching is not
+// available, e.g. because the prompt is below the model's minimum size;
+// the call then sends the system prompt inline as usual.
+func (p *PromptCache) Name(ctx context.Context, model string, systemPrompt string) string {
+	if model == "" {
+		model = p.client.defaultModel
+	}
+	key := sha256.Sum256([]byte(model + "\x00" + systemPrompt))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.entries[key]
+	if ok && time.Until(entry.ExpireTime) > cacheRenewMargin {
+		return entry.Name
+	}
+	if ok {
+		expireTime, err := p.client.ExtendCachedContent(ctx, entry.Name, p.ttl)
+		if err == nil {
+			entry.ExpireTime = expireTime
+			p.entries[key] = entry
+			return entry.Name
+		}
+		logging.Warn(ctx, "failed to extend Gemini cached content %s, creating a new one: %v", entry.Name, err)
+		delete(p.entries, key)
+	}
+
+	entry, err := p.client.CacheSystemPrompt(ctx, model, systemPrompt, p.ttl)
+	if err != nil {
+		logging.Warn(ctx, "Gemini context caching is not available, sending the system prompt inline: %v", err)
+		return ""
+	}
+	p.entries[key] = entry
+	return entry.Name
+}
+
+// Options returns opts with CachedContent set to the cached content
+// holding message's system prompt for opts.Model, when caching is
+// available.
+func (p *PromptCache) Options(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) models.AIClientOptions {
+	if message.SystemPrompt != "" {
+		opts.CachedContent = p.Name(ctx, opts.Model, message.SystemPrompt)
+	}
+	return opts
+}
+
+// Close deletes every cached content the cache created.
+func (p *PromptCache) Close(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for key, entry := range p.entries {
+		errs = append(errs, p.client.DeleteCachedContent(ctx, entry.Name))
+		delete(p.entries, key)
+	}
+	return errors.Join(errs...)
+}
+
+// useCachedContent reports whether a request with opts and systemPrompt
+// can use opts.CachedContent. Gemini rejects a cached content together
+// with tools, and a cached content this client created for another system
+// prompt would silently replace systemPrompt; both send it inline instead.
+// Cached contents created elsewhere are trusted to hold systemPrompt.
+func (c *Client) useCachedContent(opts models.AIClientOptions, systemPrompt string) bool {
+	if opts.CachedContent == "" || len(opts.Tools) > 0 {
+		return false
+	}
+	hash, ok := c.cachedPrompts.Load(opts.CachedContent)
+	return !ok || hash == sha256.Sum256([]byte(systemPrompt))
+}
@@ -2,9 +2,16 @@ package gemini
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"os"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/A-pen-app/ai-client/models"
 	"github.com/A-pen-app/ai-client/store"
@@ -12,20 +19,77 @@ import (
 	"google.golang.org/genai"
 )
 
+// maxTemperature is the upper bound Gemini accepts for temperature.
+const maxTemperature = 2.0
+
+// maxImages is the default AIClientOptions.MaxImages, the most images Gemini
+// accepts in one request.
+const maxImages = 3000
+
+// maxPenalty bounds the frequency and presence penalties Gemini accepts.
+const maxPenalty = 2.0
+
 // Client wraps Gemini API client and implements the AIClient interface
 type Client struct {
 	client       *genai.Client
 	defaultModel string
+	// httpClient is the copy of the caller-supplied HTTP client, or of a
+	// zero one, that carries the header transport.
+	httpClient *http.Client
+	// maxImageDim and jpegQuality configure image preprocessing; a zero
+	// maxImageDim disables it.
+	maxImageDim int
+	jpegQuality int
+	// convertHEIC converts HEIC images to JPEG before they are attached.
+	convertHEIC bool
+	// imageResolver fetches ImageUrls; nil uses util.HTTPImageResolver.
+	imageResolver util.ImageResolver
+	// cachedPrompts maps the names of cached contents created by
+	// CacheSystemPrompt to the SHA-256 of their system prompt.
+	cachedPrompts sync.Map
+	closed        atomic.Bool
 }
 
 // NewClient creates a new Gemini API client
-func NewClient(projectID string, location string, model string) (store.AIClient, error) {
+func NewClient(projectID string, location string, model string, opts ...Option) (store.AIClient, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	config, err := clientConfig(projectID, location, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// The User-Agent is always set, so requests always go through a header
+	// transport on a copy of the caller's HTTP client.
+	headers := o.headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	if headers.Get("User-Agent") == "" {
+		userAgent := o.userAgent
+		if userAgent == "" {
+			userAgent = util.DefaultUserAgent()
+		}
+		headers.Set("User-Agent", userAgent)
+	}
+
+	var httpClient http.Client
+	if o.httpClient != nil {
+		httpClient = *o.httpClient
+	}
+	httpClient.Transport = newHeaderTransport(httpClient.Transport, headers)
+	config.HTTPClient = &httpClient
+	if config.Backend == genai.BackendVertexAI {
+		if err := config.UseDefaultCredentials(); err != nil {
+			return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+		}
+	}
+
 	ctx := context.Background()
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		Backend:  2,
-		Project:  projectID,
-		Location: location,
-	})
+	client, err := genai.NewClient(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
 	}
@@ -34,16 +98,339 @@ func NewClient(projectID string, location string, model string) (store.AIClient,
 		model = "gemini-2.5-flash"
 	}
 	return &Client{
-		client:       client,
-		defaultModel: model,
+		client:        client,
+		defaultModel:  model,
+		httpClient:    config.HTTPClient,
+		maxImageDim:   o.maxImageDim,
+		jpegQuality:   o.jpegQuality,
+		convertHEIC:   o.convertHEIC,
+		imageResolver: o.imageResolver,
 	}, nil
 }
 
+// clientConfig validates the fields the selected backend needs and returns
+// the matching genai configuration.
+func clientConfig(projectID string, location string, o options) (*genai.ClientConfig, error) {
+	switch o.backend {
+	case "", BackendVertexAI:
+		if projectID == "" && os.Getenv("GOOGLE_CLOUD_PROJECT") == "" {
+			return nil, fmt.Errorf("project ID is required for the Vertex AI backend")
+		}
+		return &genai.ClientConfig{
+			Backend:  genai.BackendVertexAI,
+			Project:  projectID,
+			Location: location,
+		}, nil
+	case BackendGeminiAPI:
+		apiKey := o.apiKey
+		if apiKey == "" {
+			apiKey = os.Getenv("GEMINI_API_KEY")
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("API key is required for the Gemini API backend; use WithAPIKey or set GEMINI_API_KEY")
+		}
+		return &genai.ClientConfig{
+			Backend: genai.BackendGeminiAPI,
+			APIKey:  apiKey,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown gemini backend %q", o.backend)
+}
+
 func (c *Client) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	text, _, err := c.GenerateWithUsage(ctx, message, opts)
+	return text, err
+}
+
+// GenerateChat replies to an ordered conversation, mapped onto a message
+// with models.NewChatMessage.
+func (c *Client) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+// GenerateWithUsage behaves like Generate and also reports token usage from
+// the response's usage metadata.
+func (c *Client) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	return result.Text, result.Usage, err
+}
+
+// GenerateWithTools returns the full result, including any function calls
+// the model requested from opts.Tools.
+func (c *Client) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	ctx, cancel := util.WithRequestTimeout(ctx, opts.RequestTimeout)
+	defer cancel()
+
+	result, err := c.generate(ctx, message, opts)
+	return result, util.TimeoutError(ctx, err)
+}
+
+func (c *Client) generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
 	if c.client == nil {
-		return "", fmt.Errorf("gemini client is not initialized")
+		return models.GenerateResult{}, fmt.Errorf("gemini client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return models.GenerateResult{}, models.ErrClientClosed
+	}
+
+	modelName, contents, config, err := c.buildRequest(ctx, message, opts)
+	if err != nil {
+		return models.GenerateResult{}, err
+	}
+
+	resp, err := c.client.Models.GenerateContent(ctx, modelName, contents, config)
+	if err != nil {
+		return models.GenerateResult{}, wrapError(fmt.Errorf("failed to generate content: %w", err))
+	}
+
+	var usage models.Usage
+	if resp.UsageMetadata != nil {
+		usage = models.Usage{
+			PromptTokens:       int64(resp.UsageMetadata.PromptTokenCount),
+			CompletionTokens:   int64(resp.UsageMetadata.CandidatesTokenCount),
+			TotalTokens:        int64(resp.UsageMetadata.TotalTokenCount),
+			CachedPromptTokens: int64(resp.UsageMetadata.CachedContentTokenCount),
+		}
+	}
+
+	result := models.GenerateResult{Usage: usage}
+	if opts.IncludeRawResponse {
+		// The SDK does not keep the response body, so re-encode the
+		// decoded response; fields the SDK does not know are lost.
+		raw, err := json.Marshal(resp)
+		if err != nil {
+			return result, fmt.Errorf("failed to encode gemini response: %w", err)
+		}
+		result.Raw = raw
+	}
+
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		result.FinishReason = models.FinishReasonContentFilter
+		return result, models.NewContentFilteredError("gemini", blockMessage(resp.PromptFeedback))
+	}
+
+	if len(resp.Candidates) == 0 {
+		return result, &models.AIError{Kind: models.ErrorKindEmptyResponse, Provider: "gemini", Err: errors.New("empty response from Gemini")}
+	}
+
+	candidate := resp.Candidates[0]
+	result.FinishReason = finishReason(candidate.FinishReason)
+	if result.FinishReason == models.FinishReasonContentFilter {
+		return result, models.NewContentFilteredError("gemini", string(candidate.FinishReason))
+	}
+
+	if candidate.Content == nil || len(candidate.Content.Parts) == 0 {
+		return result, &models.AIError{Kind: models.ErrorKindEmptyResponse, Provider: "gemini", Err: errors.New("empty content in Gemini response")}
+	}
+
+	result.Text = candidateText(candidate)
+	result.Thoughts = candidateThoughts(candidate)
+
+	if opts.LogProbs {
+		result.AvgLogProb = candidate.AvgLogprobs
+		if candidate.LogprobsResult != nil {
+			result.LogProbs = tokenLogProbs(candidate.LogprobsResult)
+			if len(result.LogProbs) > 0 {
+				result.AvgLogProb = models.AverageLogProb(result.LogProbs)
+			}
+		}
+	}
+
+	if opts.N > 1 {
+		if len(resp.Candidates) < opts.N {
+			return result, fmt.Errorf("gemini returned %d candidates, requested %d", len(resp.Candidates), opts.N)
+		}
+		for _, candidate := range resp.Candidates {
+			if candidate.Content == nil {
+				result.Candidates = append(result.Candidates, "")
+				continue
+			}
+			result.Candidates = append(result.Candidates, candidateText(candidate))
+		}
+	}
+
+	result.Parts, err = responseParts(candidate)
+	if err != nil {
+		return result, err
+	}
+	for _, part := range result.Parts {
+		if part.ToolCall != nil {
+			result.ToolCalls = append(result.ToolCalls, *part.ToolCall)
+		}
+	}
+	if len(result.ToolCalls) > 0 && result.FinishReason == models.FinishReasonStop {
+		result.FinishReason = models.FinishReasonToolCalls
+	}
+
+	// Without tools, a reply without text is unusable; say what came back
+	// instead of returning an empty string.
+	if result.Text == "" && (len(opts.Tools) == 0 || len(result.ToolCalls) == 0) {
+		return result, &models.AIError{Kind: models.ErrorKindEmptyResponse, Provider: "gemini", Err: fmt.Errorf("gemini response has no text, only %s parts", partKinds(result.Parts))}
+	}
+
+	return result, nil
+}
+
+// GenerateN returns every candidate requested with opts.N. With N <= 1 it
+// returns the single Generate result.
+func (c *Client) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Texts(), nil
+}
+
+// StreamGenerate streams the response text as it is produced. The returned
+// channel is closed once the stream ends; the last chunk has Done set and
+// carries any provider or context error.
+func (c *Client) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	ctx, cancel := util.WithRequestTimeout(ctx, opts.RequestTimeout)
+
+	ch, err := c.stream(ctx, message, opts, cancel)
+	if err != nil {
+		cancel()
+		return nil, util.TimeoutError(ctx, err)
+	}
+	return ch, nil
+}
+
+// stream starts the provider stream. On success the producing goroutine
+// owns cancel and calls it when the stream ends.
+func (c *Client) stream(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions, cancel context.CancelFunc) (<-chan models.StreamChunk, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("gemini client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return nil, models.ErrClientClosed
+	}
+
+	// Streams carry a single candidate and no log probabilities.
+	opts.N = 0
+	opts.LogProbs, opts.TopLogProbs = false, 0
+
+	modelName, contents, config, err := c.buildRequest(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan models.StreamChunk)
+	go func() {
+		defer close(ch)
+		defer cancel()
+
+		for resp, err := range c.client.Models.GenerateContentStream(ctx, modelName, contents, config) {
+			if err != nil {
+				util.FinishStream(ctx, ch, wrapError(fmt.Errorf("failed to stream content: %w", err)))
+				return
+			}
+			if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+				continue
+			}
+			text := candidateText(resp.Candidates[0])
+			if text == "" {
+				continue
+			}
+			if !util.SendChunk(ctx, ch, models.StreamChunk{Text: text}) {
+				util.FinishStream(ctx, ch, nil)
+				return
+			}
+		}
+
+		util.FinishStream(ctx, ch, nil)
+	}()
+
+	return ch, nil
+}
+
+// HealthCheck retrieves the default model, which verifies connectivity,
+// credentials and the model name without generating any tokens.
+func (c *Client) HealthCheck(ctx context.Context) error {
+	if c.client == nil {
+		return fmt.Errorf("gemini client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return models.ErrClientClosed
+	}
+
+	if _, err := c.client.Models.Get(ctx, c.defaultModel, nil); err != nil {
+		return wrapError(fmt.Errorf("failed to get model: %w", err))
+	}
+	return nil
+}
+
+// Close releases the idle connections of the client's HTTP client and makes
+// later calls fail with models.ErrClientClosed. It is safe to call more than
+// once.
+func (c *Client) Close() error {
+	c.closed.Store(true)
+	if c.httpClient != nil {
+		c.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// buildRequest maps an AIChatMessage and options onto the Gemini request shape.
+func (c *Client) buildRequest(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, []*genai.Content, *genai.GenerateContentConfig, error) {
+	opts = util.AdaptMaxTokens(ctx, opts)
+
+	if err := opts.ValidateSampling(maxTemperature); err != nil {
+		return "", nil, nil, err
+	}
+
+	if err := opts.ValidatePenalties(maxPenalty); err != nil {
+		return "", nil, nil, err
+	}
+
+	if err := opts.ValidateResponseFormat(); err != nil {
+		return "", nil, nil, err
+	}
+
+	if err := opts.ValidateImageDetail(); err != nil {
+		return "", nil, nil, err
+	}
+
+	if err := opts.ValidateReasoningEffort(); err != nil {
+		return "", nil, nil, err
+	}
+
+	if err := opts.ValidateThinkingBudget(); err != nil {
+		return "", nil, nil, err
 	}
 
+	if err := opts.UnsupportedLogitBias("gemini"); err != nil {
+		return "", nil, nil, err
+	}
+
+	if err := opts.ValidateLogProbs(); err != nil {
+		return "", nil, nil, err
+	}
+
+	if message.AssistantPrefill != "" {
+		return "", nil, nil, fmt.Errorf("assistant prefill is not supported by gemini; use ResponseFormat instead")
+	}
+
+	if err := message.ValidateHistory(); err != nil {
+		return "", nil, nil, err
+	}
+
+	if err := opts.ValidateImageCount("gemini", message, maxImages); err != nil {
+		return "", nil, nil, err
+	}
+
+	if err := opts.ValidateModel("gemini"); err != nil {
+		return "", nil, nil, err
+	}
+
+	message = util.FoldSystemPrompt(message, opts)
+
 	modelName := c.defaultModel
 	if opts.Model != "" {
 		modelName = opts.Model
@@ -55,54 +442,418 @@ func (c *Client) Generate(ctx context.Context, message models.AIChatMessage, opt
 		contentParts = append(contentParts, genai.NewPartFromText(message.Text))
 	}
 
-	for _, url := range message.ImageUrls {
-		imageData, err := util.DownloadImage(ctx, url)
-		if err != nil {
-			return "", fmt.Errorf("failed to download image: %w", err)
-		}
-		mimeType := http.DetectContentType(imageData)
-		contentParts = append(contentParts, genai.NewPartFromBytes(imageData, mimeType))
+	imageParts, err := c.imageParts(ctx, message)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	contentParts = append(contentParts, imageParts...)
+
+	audio, err := util.LoadAudio(ctx, message)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	for _, clip := range audio {
+		contentParts = append(contentParts, genai.NewPartFromBytes(clip.Bytes, clip.ContentType()))
+	}
+
+	documents, err := util.LoadDocuments(ctx, message)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	for _, document := range documents {
+		contentParts = append(contentParts, genai.NewPartFromBytes(document.Bytes, document.MimeType))
 	}
 
-	contents := []*genai.Content{
-		genai.NewContentFromParts(contentParts, genai.RoleUser),
+	contents := make([]*genai.Content, 0, len(message.History)+1)
+	for _, turn := range message.History {
+		switch turn.Role {
+		case models.RoleUser:
+			if !turn.HasImages() {
+				contents = append(contents, genai.NewContentFromText(turn.Text, genai.RoleUser))
+				break
+			}
+			var parts []*genai.Part
+			if turn.Text != "" {
+				parts = append(parts, genai.NewPartFromText(turn.Text))
+			}
+			imageParts, err := c.imageParts(ctx, turn.ImageMessage(message.ImageMimeType))
+			if err != nil {
+				return "", nil, nil, err
+			}
+			contents = append(contents, genai.NewContentFromParts(append(parts, imageParts...), genai.RoleUser))
+		case models.RoleAssistant:
+			contents = append(contents, genai.NewContentFromText(turn.Text, genai.RoleModel))
+		default:
+			return "", nil, nil, fmt.Errorf("unsupported turn role %q", turn.Role)
+		}
 	}
+	contents = append(contents, genai.NewContentFromParts(contentParts, genai.RoleUser))
 
 	// Build generation config
 	config := &genai.GenerateContentConfig{}
 
-	if message.SystemPrompt != "" {
-		config.SystemInstruction = genai.NewContentFromText(message.SystemPrompt, genai.RoleUser)
+	if c.useCachedContent(opts, message.SystemPrompt) {
+		// The cached content carries the system prompt; Gemini rejects a
+		// request that also sets one.
+		config.CachedContent = opts.CachedContent
+	} else if message.SystemPrompt != "" {
+		// System instructions carry no role; NewContentFromText would default
+		// to the user role and the model may then treat it as a user turn.
+		config.SystemInstruction = &genai.Content{
+			Parts: []*genai.Part{genai.NewPartFromText(message.SystemPrompt)},
+		}
 	}
 
-	if opts.ResponseFormat == models.ResponseFormatJSON {
+	switch opts.ResponseFormat {
+	case models.ResponseFormatJSON:
+		config.ResponseMIMEType = "application/json"
+	case models.ResponseFormatJSONSchema:
 		config.ResponseMIMEType = "application/json"
+		config.ResponseJsonSchema = opts.ResponseSchema
 	}
 
 	if opts.MaxTokens > 0 {
 		config.MaxOutputTokens = int32(opts.MaxTokens)
 	}
 
-	resp, err := c.client.Models.GenerateContent(ctx, modelName, contents, config)
+	if len(opts.Tools) > 0 {
+		declarations := make([]*genai.FunctionDeclaration, 0, len(opts.Tools))
+		for _, tool := range opts.Tools {
+			declarations = append(declarations, &genai.FunctionDeclaration{
+				Name:                 tool.Name,
+				Description:          tool.Description,
+				ParametersJsonSchema: tool.Parameters,
+			})
+		}
+		config.Tools = []*genai.Tool{{FunctionDeclarations: declarations}}
+	}
+
+	if opts.Temperature != nil {
+		config.Temperature = genai.Ptr(float32(*opts.Temperature))
+	}
+
+	if opts.TopP != nil {
+		config.TopP = genai.Ptr(float32(*opts.TopP))
+	}
+
+	if opts.N > 1 {
+		config.CandidateCount = int32(opts.N)
+	}
+
+	if opts.LogProbs {
+		config.ResponseLogprobs = true
+		if opts.TopLogProbs > 0 {
+			config.Logprobs = genai.Ptr(int32(opts.TopLogProbs))
+		}
+	}
+
+	if opts.FrequencyPenalty != nil {
+		config.FrequencyPenalty = genai.Ptr(float32(*opts.FrequencyPenalty))
+	}
+
+	if opts.PresencePenalty != nil {
+		config.PresencePenalty = genai.Ptr(float32(*opts.PresencePenalty))
+	}
+
+	if opts.Seed != nil {
+		if *opts.Seed < math.MinInt32 || *opts.Seed > math.MaxInt32 {
+			return "", nil, nil, fmt.Errorf("seed %d is out of range for Gemini", *opts.Seed)
+		}
+		config.Seed = genai.Ptr(int32(*opts.Seed))
+	}
+
+	for _, setting := range opts.SafetySettings {
+		config.SafetySettings = append(config.SafetySettings, &genai.SafetySetting{
+			Category:  genai.HarmCategory(setting.Category),
+			Threshold: genai.HarmBlockThreshold(setting.Threshold),
+		})
+	}
+
+	switch opts.ImageDetail {
+	case models.ImageDetailLow:
+		config.MediaResolution = genai.MediaResolutionLow
+	case models.ImageDetailHigh:
+		config.MediaResolution = genai.MediaResolutionHigh
+	}
+
+	if opts.ReasoningEffort != "" {
+		thinking := thinkingConfig(modelName, opts.ReasoningEffort)
+		if thinking == nil {
+			if err := opts.UnsupportedReasoning("gemini", modelName); err != nil {
+				return "", nil, nil, err
+			}
+		}
+		config.ThinkingConfig = thinking
+	}
+
+	if opts.ThinkingBudget != nil {
+		if *opts.ThinkingBudget > math.MaxInt32 {
+			return "", nil, nil, fmt.Errorf("thinking budget %d is out of range for Gemini", *opts.ThinkingBudget)
+		}
+		config.ThinkingConfig = &genai.ThinkingConfig{ThinkingBudget: genai.Ptr(int32(*opts.ThinkingBudget))}
+	}
+
+	if opts.IncludeThoughts {
+		if config.ThinkingConfig == nil {
+			config.ThinkingConfig = &genai.ThinkingConfig{}
+		}
+		config.ThinkingConfig.IncludeThoughts = true
+	}
+
+	return modelName, contents, config, nil
+}
+
+// thinkingBudgets maps reasoning efforts onto Gemini 2.5 thinking budgets
+// in tokens.
+var thinkingBudgets = map[models.ReasoningEffort]int32{
+	models.ReasoningEffortLow:    1024,
+	models.ReasoningEffortMedium: 8192,
+	models.ReasoningEffortHigh:   24576,
+}
+
+// thinkingConfig maps effort onto the thinking configuration of model, or
+// returns nil when the model does not think. Gemini 2.5 takes a token
+// budget and Gemini 3 a thinking level, which has no medium setting.
+func thinkingConfig(model string, effort models.ReasoningEffort) *genai.ThinkingConfig {
+	switch {
+	case strings.HasPrefix(model, "gemini-2.5"):
+		return &genai.ThinkingConfig{ThinkingBudget: genai.Ptr(thinkingBudgets[effort])}
+	case strings.HasPrefix(model, "gemini-3"):
+		if effort == models.ReasoningEffortLow {
+			return &genai.ThinkingConfig{ThinkingLevel: genai.ThinkingLevelLow}
+		}
+		return &genai.ThinkingConfig{ThinkingLevel: genai.ThinkingLevelHigh}
+	}
+	return nil
+}
+
+// imageParts returns the images of message, loaded and preprocessed, as
+// inline parts.
+func (c *Client) imageParts(ctx context.Context, message models.AIChatMessage) ([]*genai.Part, error) {
+	images, err := util.LoadImagesWith(ctx, message, c.imageResolver)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate content: %w", err)
+		return nil, err
+	}
+	if c.convertHEIC {
+		if images, err = util.ConvertHEICImages(images, c.jpegQuality); err != nil {
+			return nil, err
+		}
 	}
+	images = util.PreprocessImages(images, c.maxImageDim, c.jpegQuality)
 
-	if len(resp.Candidates) == 0 {
-		return "", fmt.Errorf("empty response from Gemini")
+	parts := make([]*genai.Part, 0, len(images))
+	for _, image := range images {
+		parts = append(parts, genai.NewPartFromBytes(image.Bytes, image.ContentType()))
 	}
+	return parts, nil
+}
 
-	candidate := resp.Candidates[0]
-	if candidate.Content == nil || len(candidate.Content.Parts) == 0 {
-		return "", fmt.Errorf("empty content in Gemini response")
+// RawRequest is a Gemini request built by the caller for GenerateRaw.
+type RawRequest struct {
+	// Model defaults to the client's default model.
+	Model    string
+	Contents []*genai.Content
+	Config   *genai.GenerateContentConfig
+}
+
+// BuildRequest returns the RawRequest Generate would send for message and
+// opts, with images downloaded and preprocessed as configured, without
+// calling the API. The result can be passed to GenerateRaw.
+func (c *Client) BuildRequest(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (any, error) {
+	model, contents, config, err := c.buildRequest(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return RawRequest{Model: model, Contents: contents, Config: config}, nil
+}
+
+// GenerateRaw sends contents and config built by the caller, bypassing the
+// AIChatMessage mapping, and returns the first candidate's text. params
+// must be a RawRequest (or a pointer to one). This is an escape hatch for
+// requests AIChatMessage cannot express and is specific to this client.
+func (c *Client) GenerateRaw(ctx context.Context, params any) (string, error) {
+	if c.client == nil {
+		return "", fmt.Errorf("gemini client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return "", models.ErrClientClosed
+	}
+
+	var request RawRequest
+	switch p := params.(type) {
+	case RawRequest:
+		request = p
+	case *RawRequest:
+		request = *p
+	default:
+		return "", fmt.Errorf("gemini GenerateRaw expects gemini.RawRequest, got %T", params)
+	}
+	if request.Model == "" {
+		request.Model = c.defaultModel
+	}
+
+	resp, err := c.client.Models.GenerateContent(ctx, request.Model, request.Contents, request.Config)
+	if err != nil {
+		return "", wrapError(fmt.Errorf("failed to generate content: %w", err))
+	}
+
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		return "", models.NewContentFilteredError("gemini", blockMessage(resp.PromptFeedback))
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return "", &models.AIError{Kind: models.ErrorKindEmptyResponse, Provider: "gemini", Err: errors.New("empty response from Gemini")}
 	}
+	if finishReason(resp.Candidates[0].FinishReason) == models.FinishReasonContentFilter {
+		return "", models.NewContentFilteredError("gemini", string(resp.Candidates[0].FinishReason))
+	}
+	return candidateText(resp.Candidates[0]), nil
+}
 
+// transcribePrompt asks the model for a plain transcript of an audio clip.
+const transcribePrompt = "Transcribe this audio verbatim in its original language. Reply with the transcript only."
+
+// Transcribe converts speech to text by sending the clip as an audio part
+// with a transcription instruction. opts apply as for Generate.
+func (c *Client) Transcribe(ctx context.Context, audio models.AudioData, opts models.AIClientOptions) (string, error) {
+	if len(audio.Bytes) == 0 {
+		return "", fmt.Errorf("audio cannot be empty")
+	}
+
+	return c.Generate(ctx, models.AIChatMessage{
+		Text:  transcribePrompt,
+		Audio: []models.AudioData{audio},
+	}, opts)
+}
+
+// Moderate classifies content by prompting the model, as Gemini has no
+// moderation endpoint. See store.NewPromptModerator.
+func (c *Client) Moderate(ctx context.Context, content string) (models.ModerationResult, error) {
+	return store.NewPromptModerator(c).Moderate(ctx, content)
+}
+
+// finishReason normalizes a Gemini candidate finish reason.
+func finishReason(reason genai.FinishReason) models.FinishReason {
+	switch reason {
+	case "", genai.FinishReasonUnspecified:
+		return ""
+	case genai.FinishReasonStop:
+		return models.FinishReasonStop
+	case genai.FinishReasonMaxTokens:
+		return models.FinishReasonLength
+	case genai.FinishReasonSafety,
+		genai.FinishReasonRecitation,
+		genai.FinishReasonBlocklist,
+		genai.FinishReasonProhibitedContent,
+		genai.FinishReasonSPII,
+		genai.FinishReasonImageSafety:
+		return models.FinishReasonContentFilter
+	}
+	return models.FinishReasonOther
+}
+
+// blockMessage describes why Gemini blocked a prompt.
+func blockMessage(feedback *genai.GenerateContentResponsePromptFeedback) string {
+	if feedback.BlockReasonMessage != "" {
+		return fmt.Sprintf("%s (%s)", feedback.BlockReason, feedback.BlockReasonMessage)
+	}
+	return string(feedback.BlockReason)
+}
+
+// candidateText concatenates the text parts of a candidate in the order they
+// were returned, skipping thought summaries and every other part.
+func candidateText(candidate *genai.Candidate) string {
+	return joinText(candidate, false)
+}
+
+// tokenLogProbs maps the log probabilities of a candidate. TopCandidates
+// is indexed by decoding step, like ChosenCandidates.
+func tokenLogProbs(logprobs *genai.LogprobsResult) []models.TokenLogProb {
+	tokens := make([]models.TokenLogProb, 0, len(logprobs.ChosenCandidates))
+	for i, chosen := range logprobs.ChosenCandidates {
+		if chosen == nil {
+			continue
+		}
+		token := models.TokenLogProb{Token: chosen.Token, LogProb: float64(chosen.LogProbability)}
+		if i < len(logprobs.TopCandidates) && logprobs.TopCandidates[i] != nil {
+			for _, top := range logprobs.TopCandidates[i].Candidates {
+				if top != nil {
+					token.TopLogProbs = append(token.TopLogProbs, models.TokenLogProb{Token: top.Token, LogProb: float64(top.LogProbability)})
+				}
+			}
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// candidateThoughts concatenates the thought summaries of a candidate.
+func candidateThoughts(candidate *genai.Candidate) string {
+	return joinText(candidate, true)
+}
+
+func joinText(candidate *genai.Candidate, thought bool) string {
 	var resultText strings.Builder
 	for _, part := range candidate.Content.Parts {
-		if part.Text != "" {
+		if part.Text != "" && part.Thought == thought {
 			resultText.WriteString(part.Text)
 		}
 	}
+	return resultText.String()
+}
 
-	return resultText.String(), nil
+// responseParts maps the parts of a candidate, in order.
+func responseParts(candidate *genai.Candidate) ([]models.ResponsePart, error) {
+	parts := make([]models.ResponsePart, 0, len(candidate.Content.Parts))
+	for _, part := range candidate.Content.Parts {
+		switch {
+		case part.Text != "" && part.Thought:
+			parts = append(parts, models.ResponsePart{Kind: models.ResponsePartThought, Text: part.Text})
+		case part.Text != "":
+			parts = append(parts, models.ResponsePart{Kind: models.ResponsePartText, Text: part.Text})
+		case part.FunctionCall != nil:
+			args, err := json.Marshal(part.FunctionCall.Args)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode function call arguments: %w", err)
+			}
+			parts = append(parts, models.ResponsePart{
+				Kind: models.ResponsePartToolCall,
+				ToolCall: &models.ToolCall{
+					ID:        part.FunctionCall.ID,
+					Name:      part.FunctionCall.Name,
+					Arguments: args,
+				},
+			})
+		case part.InlineData != nil:
+			parts = append(parts, models.ResponsePart{
+				Kind:     models.ResponsePartInlineData,
+				Data:     part.InlineData.Data,
+				MimeType: part.InlineData.MIMEType,
+			})
+		default:
+			parts = append(parts, models.ResponsePart{Kind: models.ResponsePartOther})
+		}
+	}
+	return parts, nil
+}
+
+// partKinds lists the distinct kinds of parts for error messages.
+func partKinds(parts []models.ResponsePart) string {
+	var kinds []string
+	for _, part := range parts {
+		if !slices.Contains(kinds, string(part.Kind)) {
+			kinds = append(kinds, string(part.Kind))
+		}
+	}
+	return strings.Join(kinds, ", ")
+}
+
+// wrapError attaches the HTTP status of a Gemini API error so callers can
+// classify it without depending on the genai package.
+func wrapError(err error) error {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return models.NewAIError("gemini", apiErr.Code, err)
+	}
+	return err
 }
@@ -0,0 +1,248 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// fakeTransport answers every request with response and records the
+// request bodies.
+type fakeTransport struct {
+	response string
+
+	mu     sync.Mutex
+	bodies [][]byte
+}
+
+func (t *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	t.bodies = append(t.bodies, body)
+	t.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(t.response)),
+		Request:    req,
+	}, nil
+}
+
+func (t *fakeTransport) lastBody() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.bodies) == 0 {
+		return nil
+	}
+	return t.bodies[len(t.bodies)-1]
+}
+
+func newTestClient(t *testing.T, transport http.RoundTripper) *Client {
+	t.Helper()
+	client, err := NewClient("", "", "gemini-2.5-flash",
+		WithBackend(BackendGeminiAPI),
+		WithAPIKey("test-key"),
+		WithHTTPClient(&http.Client{Transport: transport}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client.(*Client)
+}
+
+// wireContent is a genai Content as sent on the wire.
+type wireContent struct {
+	Role  string `json:"role"`
+	Parts []struct {
+		Text string `json:"text"`
+	} `json:"parts"`
+}
+
+func (c wireContent) text() string {
+	var texts []string
+	for _, part := range c.Parts {
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "")
+}
+
+func TestGenerateSendsSystemPromptAsSystemInstruction(t *testing.T) {
+	const systemPrompt = "You are an OCR engine. Respond only with a JSON object of the form {\"name\": string}."
+	const answer = `{"name":"Alice"}`
+
+	transport := &fakeTransport{response: `{
+		"candidates": [{
+			"content": {"role": "model", "parts": [{"text": "{\"name\":\"Alice\"}"}]},
+			"finishReason": "STOP"
+		}]
+	}`}
+	client := newTestClient(t, transport)
+
+	message := models.AIChatMessage{
+		SystemPrompt: systemPrompt,
+		Text:         "Read the name on this card.",
+	}
+	opts := models.AIClientOptions{ResponseFormat: models.ResponseFormatJSON}
+
+	built, err := client.BuildRequest(context.Background(), message, opts)
+	if err != nil {
+		t.Fatalf("BuildRequest: %v", err)
+	}
+	config := built.(RawRequest).Config
+	if config.SystemInstruction == nil {
+		t.Fatal("SystemInstruction is not set")
+	}
+	if config.SystemInstruction.Role != "" {
+		t.Errorf("SystemInstruction role = %q, want none", config.SystemInstruction.Role)
+	}
+
+	text, err := client.Generate(context.Background(), message, opts)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if text != answer {
+		t.Errorf("Generate = %q, want %q", text, answer)
+	}
+	if strings.Contains(text, systemPrompt) {
+		t.Errorf("system prompt leaked into the output: %q", text)
+	}
+
+	var request struct {
+		SystemInstruction *wireContent  `json:"systemInstruction"`
+		Contents          []wireContent `json:"contents"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(transport.lastBody())).Decode(&request); err != nil {
+		t.Fatalf("decoding request body: %v", err)
+	}
+	if request.SystemInstruction == nil || request.SystemInstruction.text() != systemPrompt {
+		t.Errorf("systemInstruction = %+v, want the system prompt", request.SystemInstruction)
+	}
+	for _, content := range request.Contents {
+		if strings.Contains(content.text(), systemPrompt) {
+			t.Errorf("system prompt sent in a %q content", content.Role)
+		}
+	}
+}
+
+func TestGenerateContentFiltered(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   string
+		wantReason string
+	}{
+		{
+			name: "blocked prompt",
+			response: `{
+				"promptFeedback": {
+					"blockReason": "SAFETY",
+					"blockReasonMessage": "The prompt was blocked due to safety."
+				}
+			}`,
+			wantReason: "SAFETY (The prompt was blocked due to safety.)",
+		},
+		{
+			name: "safety finish",
+			response: `{
+				"candidates": [{
+					"finishReason": "SAFETY",
+					"safetyRatings": [{"category": "HARM_CATEGORY_DANGEROUS_CONTENT", "probability": "HIGH", "blocked": true}]
+				}]
+			}`,
+			wantReason: "SAFETY",
+		},
+		{
+			name: "prohibited content finish",
+			response: `{
+				"candidates": [{
+					"content": {"role": "model", "parts": [{"text": "partial"}]},
+					"finishReason": "PROHIBITED_CONTENT"
+				}]
+			}`,
+			wantReason: "PROHIBITED_CONTENT",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, &fakeTransport{response: tt.response})
+
+			result, err := client.GenerateWithTools(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{})
+
+			var aiErr *models.AIError
+			if !errors.As(err, &aiErr) {
+				t.Fatalf("GenerateWithTools error = %v, want a *models.AIError", err)
+			}
+			if aiErr.Kind != models.ErrorKindContentFiltered {
+				t.Errorf("Kind = %q, want %q", aiErr.Kind, models.ErrorKindContentFiltered)
+			}
+			if aiErr.Reason != tt.wantReason {
+				t.Errorf("Reason = %q, want %q", aiErr.Reason, tt.wantReason)
+			}
+			if aiErr.Provider != "gemini" {
+				t.Errorf("Provider = %q, want %q", aiErr.Provider, "gemini")
+			}
+			if result.FinishReason != models.FinishReasonContentFilter {
+				t.Errorf("FinishReason = %q, want %q", result.FinishReason, models.FinishReasonContentFilter)
+			}
+		})
+	}
+}
+
+func TestGenerateSeed(t *testing.T) {
+	transport := &fakeTransport{response: `{
+		"candidates": [{
+			"content": {"role": "model", "parts": [{"text": "{\"name\":\"Alice\"}"}]},
+			"finishReason": "STOP"
+		}]
+	}`}
+	client := newTestClient(t, transport)
+
+	seed, temperature := int64(42), 0.0
+	opts := models.AIClientOptions{Seed: &seed, Temperature: &temperature}
+	message := models.AIChatMessage{Text: "Read the name on this card."}
+
+	var texts []string
+	for range 2 {
+		text, err := client.Generate(context.Background(), message, opts)
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		texts = append(texts, text)
+
+		var request struct {
+			GenerationConfig struct {
+				Seed        *int32   `json:"seed"`
+				Temperature *float64 `json:"temperature"`
+			} `json:"generationConfig"`
+		}
+		if err := json.Unmarshal(transport.lastBody(), &request); err != nil {
+			t.Fatalf("decoding request body: %v", err)
+		}
+		if got := request.GenerationConfig.Seed; got == nil || *got != 42 {
+			t.Errorf("generationConfig.seed = %v, want 42", got)
+		}
+		if got := request.GenerationConfig.Temperature; got == nil || *got != 0 {
+			t.Errorf("generationConfig.temperature = %v, want 0", got)
+		}
+	}
+	if texts[0] != texts[1] {
+		t.Errorf("Generate returned %q and then %q, want identical text", texts[0], texts[1])
+	}
+
+	outOfRange := int64(1) << 40
+	if _, err := client.Generate(context.Background(), message, models.AIClientOptions{Seed: &outOfRange}); err == nil {
+		t.Error("Generate accepted a seed outside the int32 range")
+	}
+}
@@ -0,0 +1,115 @@
+package gemini
+
+import (
+	"net/http"
+
+	"github.com/A-pen-app/ai-client/util"
+)
+
+// Option configures a Client created by NewClient.
+type Option func(*options)
+
+type options struct {
+	backend       Backend
+	apiKey        string
+	httpClient    *http.Client
+	headers       http.Header
+	userAgent     string
+	maxImageDim   int
+	jpegQuality   int
+	convertHEIC   bool
+	imageResolver util.ImageResolver
+}
+
+// Backend selects the API a Client talks to.
+type Backend string
+
+const (
+	// BackendVertexAI calls Gemini through Vertex AI in the project and
+	// location passed to NewClient, authenticated with Google default
+	// credentials. It is the default.
+	BackendVertexAI Backend = "vertex_ai"
+	// BackendGeminiAPI calls the Gemini Developer API (Generative Language
+	// API) with an API key, set with WithAPIKey or the GEMINI_API_KEY
+	// environment variable. NewClient ignores its project and location.
+	BackendGeminiAPI Backend = "gemini_api"
+)
+
+// WithBackend selects the API the client talks to, e.g. BackendGeminiAPI
+// outside GCP.
+func WithBackend(backend Backend) Option {
+	return func(o *options) {
+		o.backend = backend
+	}
+}
+
+// WithAPIKey sets the API key of BackendGeminiAPI, taking precedence over
+// the GEMINI_API_KEY environment variable.
+func WithAPIKey(apiKey string) Option {
+	return func(o *options) {
+		o.apiKey = apiKey
+	}
+}
+
+// WithHTTPClient sends requests through client, e.g. to route them via a
+// proxy or tune connection pooling. Google default credentials are added to
+// a copy of client, so the caller's client is left untouched.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithHeaders sends headers with every request, including streaming ones,
+// e.g. a tenant ID or the authentication header of a gateway. They are
+// added by the HTTP client's transport.
+func WithHeaders(headers map[string]string) Option {
+	return func(o *options) {
+		if o.headers == nil {
+			o.headers = make(http.Header)
+		}
+		for key, value := range headers {
+			o.headers.Set(key, value)
+		}
+	}
+}
+
+// WithUserAgent sends userAgent as the User-Agent of every request,
+// including streaming ones, instead of util.DefaultUserAgent. A User-Agent
+// set with WithHeaders takes precedence.
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithImagePreprocessing downscales images whose longer side exceeds maxDim
+// pixels and re-encodes them as JPEG at jpegQuality (1-100, default 85)
+// before they are attached, see util.PreprocessImage. High-resolution photos
+// then cost fewer tokens with no loss in OCR accuracy.
+func WithImagePreprocessing(maxDim int, jpegQuality int) Option {
+	return func(o *options) {
+		o.maxImageDim = maxDim
+		o.jpegQuality = jpegQuality
+	}
+}
+
+// WithHEICConversion converts HEIC images, such as iPhone photos, to JPEG
+// before they are attached, since most providers reject HEIC. It needs a
+// binary built with -tags heic; otherwise requests with HEIC images fail
+// with util.ErrHEICUnsupported. The JPEG quality is the one set with
+// WithImagePreprocessing, or 85.
+func WithHEICConversion() Option {
+	return func(o *options) {
+		o.convertHEIC = true
+	}
+}
+
+// WithImageResolver fetches ImageUrls with resolver instead of
+// util.HTTPImageResolver, e.g. to read object-store keys or sign URLs
+// before downloading them.
+func WithImageResolver(resolver util.ImageResolver) Option {
+	return func(o *options) {
+		o.imageResolver = resolver
+	}
+}
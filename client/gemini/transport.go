@@ -0,0 +1,36 @@
+package gemini
+
+import "net/http"
+
+// headerTransport adds static headers to every request before passing it
+// on to base.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+// newHeaderTransport wraps base, or a private copy of the default transport
+// when base is nil, so that closing idle connections does not affect other
+// clients.
+func newHeaderTransport(base http.RoundTripper, headers http.Header) *headerTransport {
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	return &headerTransport{base: base, headers: headers}
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the caller's request.
+	req = req.Clone(req.Context())
+	for key, values := range t.headers {
+		req.Header[key] = values
+	}
+	return t.base.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the base transport.
+func (t *headerTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
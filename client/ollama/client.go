@@ -0,0 +1,564 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+	"github.com/A-pen-app/ai-client/util"
+)
+
+const defaultBaseURL = "http://localhost:11434"
+
+// maxTemperature is the upper bound accepted for temperature.
+const maxTemperature = 2.0
+
+// maxPenalty bounds the frequency and presence penalties Ollama accepts.
+const maxPenalty = 2.0
+
+// Client talks to a self-hosted Ollama server and implements the AIClient interface
+type Client struct {
+	httpClient   *http.Client
+	baseURL      string
+	defaultModel string
+	// maxImageDim and jpegQuality configure image preprocessing; a zero
+	// maxImageDim disables it.
+	maxImageDim int
+	jpegQuality int
+	// convertHEIC converts HEIC images to JPEG before they are attached.
+	convertHEIC bool
+	// imageResolver fetches ImageUrls; nil uses util.HTTPImageResolver.
+	imageResolver util.ImageResolver
+	closed        atomic.Bool
+}
+
+// NewClient creates a new Ollama client
+func NewClient(baseURL string, model string, opts ...Option) (store.AIClient, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	if model == "" {
+		model = "llama3.2-vision"
+	}
+
+	return &Client{
+		httpClient:    &http.Client{},
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		defaultModel:  model,
+		maxImageDim:   o.maxImageDim,
+		jpegQuality:   o.jpegQuality,
+		convertHEIC:   o.convertHEIC,
+		imageResolver: o.imageResolver,
+	}, nil
+}
+
+type chatMessage struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Images    []string   `json:"images,omitempty"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+}
+
+type toolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type toolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type toolDefinition struct {
+	Type     string       `json:"type"`
+	Function toolFunction `json:"function"`
+}
+
+type showRequest struct {
+	Model string `json:"model"`
+}
+
+type chatRequest struct {
+	Model    string           `json:"model"`
+	Messages []chatMessage    `json:"messages"`
+	Stream   bool             `json:"stream"`
+	Format   any              `json:"format,omitempty"`
+	Options  map[string]any   `json:"options,omitempty"`
+	Tools    []toolDefinition `json:"tools,omitempty"`
+}
+
+type chatResponse struct {
+	Message         chatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	DoneReason      string      `json:"done_reason,omitempty"`
+	Error           string      `json:"error,omitempty"`
+	PromptEvalCount int64       `json:"prompt_eval_count,omitempty"`
+	EvalCount       int64       `json:"eval_count,omitempty"`
+}
+
+func (c *Client) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	text, _, err := c.GenerateWithUsage(ctx, message, opts)
+	return text, err
+}
+
+// GenerateChat replies to an ordered conversation, mapped onto a message
+// with models.NewChatMessage.
+func (c *Client) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+// GenerateWithUsage behaves like Generate and also reports token usage from
+// the prompt and generation eval counts.
+func (c *Client) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	return result.Text, result.Usage, err
+}
+
+// GenerateWithTools returns the full result, including any tool calls the
+// model requested from opts.Tools.
+func (c *Client) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	ctx, cancel := util.WithRequestTimeout(ctx, opts.RequestTimeout)
+	defer cancel()
+
+	result, err := c.generate(ctx, message, opts)
+	return result, util.TimeoutError(ctx, err)
+}
+
+func (c *Client) generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	if c.httpClient == nil {
+		return models.GenerateResult{}, fmt.Errorf("ollama client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return models.GenerateResult{}, models.ErrClientClosed
+	}
+
+	req, err := c.buildRequest(ctx, message, opts, false)
+	if err != nil {
+		return models.GenerateResult{}, err
+	}
+
+	resp, err := c.post(ctx, "/api/chat", req)
+	if err != nil {
+		return models.GenerateResult{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return models.GenerateResult{}, fmt.Errorf("failed to read ollama response: %w", err)
+	}
+
+	var chatResp chatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return models.GenerateResult{}, fmt.Errorf("failed to decode ollama response: %w", err)
+	}
+
+	result := models.GenerateResult{
+		Text: message.AssistantPrefill + chatResp.Message.Content,
+		Usage: models.Usage{
+			PromptTokens:     chatResp.PromptEvalCount,
+			CompletionTokens: chatResp.EvalCount,
+			TotalTokens:      chatResp.PromptEvalCount + chatResp.EvalCount,
+		},
+		FinishReason: finishReason(chatResp.DoneReason),
+	}
+	if opts.IncludeRawResponse {
+		result.Raw = body
+	}
+
+	for _, call := range chatResp.Message.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, models.ToolCall{
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+
+	if len(result.ToolCalls) > 0 && result.FinishReason == models.FinishReasonStop {
+		result.FinishReason = models.FinishReasonToolCalls
+	}
+
+	if chatResp.Message.Content == "" && len(result.ToolCalls) == 0 {
+		return result, &models.AIError{Kind: models.ErrorKindEmptyResponse, Provider: "ollama", Err: errors.New("empty response content from Ollama")}
+	}
+
+	return result, nil
+}
+
+// GenerateN returns every candidate requested with opts.N. With N <= 1 it
+// returns the single Generate result.
+func (c *Client) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Texts(), nil
+}
+
+// StreamGenerate streams the response text as it is produced. The returned
+// channel is closed once the stream ends; the last chunk has Done set and
+// carries any provider or context error.
+func (c *Client) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	ctx, cancel := util.WithRequestTimeout(ctx, opts.RequestTimeout)
+
+	ch, err := c.stream(ctx, message, opts, cancel)
+	if err != nil {
+		cancel()
+		return nil, util.TimeoutError(ctx, err)
+	}
+	return ch, nil
+}
+
+// stream starts the provider stream. On success the producing goroutine
+// owns cancel and calls it when the stream ends.
+func (c *Client) stream(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions, cancel context.CancelFunc) (<-chan models.StreamChunk, error) {
+	if c.httpClient == nil {
+		return nil, fmt.Errorf("ollama client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return nil, models.ErrClientClosed
+	}
+
+	req, err := c.buildRequest(ctx, message, opts, true)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, "/api/chat", req)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan models.StreamChunk)
+	go func() {
+		defer close(ch)
+		defer cancel()
+		defer resp.Body.Close()
+
+		if message.AssistantPrefill != "" && !util.SendChunk(ctx, ch, models.StreamChunk{Text: message.AssistantPrefill}) {
+			util.FinishStream(ctx, ch, nil)
+			return
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var event chatResponse
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				util.FinishStream(ctx, ch, fmt.Errorf("failed to decode ollama stream: %w", err))
+				return
+			}
+			if event.Error != "" {
+				util.FinishStream(ctx, ch, fmt.Errorf("ollama stream error: %s", event.Error))
+				return
+			}
+			if event.Message.Content != "" {
+				if !util.SendChunk(ctx, ch, models.StreamChunk{Text: event.Message.Content}) {
+					util.FinishStream(ctx, ch, nil)
+					return
+				}
+			}
+			if event.Done {
+				break
+			}
+		}
+
+		util.FinishStream(ctx, ch, scanner.Err())
+	}()
+
+	return ch, nil
+}
+
+// BuildRequest returns the /api/chat request body Generate would post for
+// message and opts, with images downloaded and preprocessed as configured,
+// without calling the server. It encodes to the exact JSON that is sent and
+// can be passed to GenerateRaw.
+func (c *Client) BuildRequest(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (any, error) {
+	return c.buildRequest(ctx, message, opts, false)
+}
+
+// GenerateRaw posts a chat request built by the caller to /api/chat,
+// bypassing the AIChatMessage mapping, and returns the reply's content.
+// params is encoded as the JSON request body and must disable streaming
+// ("stream": false). This is an escape hatch specific to this client.
+func (c *Client) GenerateRaw(ctx context.Context, params any) (string, error) {
+	if c.httpClient == nil {
+		return "", fmt.Errorf("ollama client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return "", models.ErrClientClosed
+	}
+
+	resp, err := c.post(ctx, "/api/chat", params)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var chatResp chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("failed to decode ollama response: %w", err)
+	}
+	return chatResp.Message.Content, nil
+}
+
+// HealthCheck asks the server to describe the default model, which
+// verifies connectivity and that the model has been pulled.
+func (c *Client) HealthCheck(ctx context.Context) error {
+	if c.httpClient == nil {
+		return fmt.Errorf("ollama client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return models.ErrClientClosed
+	}
+
+	resp, err := c.post(ctx, "/api/show", showRequest{Model: c.defaultModel})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Close releases the client's idle connections and makes later calls fail
+// with models.ErrClientClosed. It is safe to call more than once.
+func (c *Client) Close() error {
+	c.closed.Store(true)
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// buildRequest maps an AIChatMessage and options onto an /api/chat request.
+func (c *Client) buildRequest(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions, stream bool) (*chatRequest, error) {
+	opts = util.AdaptMaxTokens(ctx, opts)
+
+	if err := opts.ValidateSampling(maxTemperature); err != nil {
+		return nil, err
+	}
+
+	if err := opts.ValidatePenalties(maxPenalty); err != nil {
+		return nil, err
+	}
+
+	if err := opts.ValidateResponseFormat(); err != nil {
+		return nil, err
+	}
+
+	if opts.N > 1 {
+		return nil, fmt.Errorf("ollama supports a single candidate, requested %d", opts.N)
+	}
+
+	if err := message.ValidateHistory(); err != nil {
+		return nil, err
+	}
+
+	// Ollama sets no image limit of its own.
+	if err := opts.ValidateImageCount("ollama", message, 0); err != nil {
+		return nil, err
+	}
+
+	if message.HasAudio() {
+		return nil, fmt.Errorf("audio input is not supported by ollama")
+	}
+
+	if len(message.DocumentUrls) > 0 {
+		return nil, fmt.Errorf("document input is not supported by ollama")
+	}
+
+	if err := opts.ValidateModel("ollama"); err != nil {
+		return nil, err
+	}
+
+	message = util.FoldSystemPrompt(message, opts)
+
+	model := c.defaultModel
+	if opts.Model != "" {
+		model = opts.Model
+	}
+
+	if err := opts.UnsupportedReasoning("ollama", model); err != nil {
+		return nil, err
+	}
+
+	if err := opts.UnsupportedLogitBias("ollama"); err != nil {
+		return nil, err
+	}
+
+	if opts.LogProbs {
+		return nil, fmt.Errorf("logprobs are not supported by ollama")
+	}
+
+	var messages []chatMessage
+	if message.SystemPrompt != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: message.SystemPrompt})
+	}
+
+	for _, turn := range message.History {
+		switch turn.Role {
+		case models.RoleUser, models.RoleAssistant:
+			turnMessage := chatMessage{Role: string(turn.Role), Content: turn.Text}
+			if turn.HasImages() {
+				images, err := c.images(ctx, turn.ImageMessage(message.ImageMimeType))
+				if err != nil {
+					return nil, err
+				}
+				turnMessage.Images = images
+			}
+			messages = append(messages, turnMessage)
+		default:
+			return nil, fmt.Errorf("unsupported turn role %q", turn.Role)
+		}
+	}
+
+	userMessage := chatMessage{Role: "user", Content: message.Text}
+	images, err := c.images(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	userMessage.Images = images
+	messages = append(messages, userMessage)
+	if message.AssistantPrefill != "" {
+		messages = append(messages, chatMessage{Role: "assistant", Content: message.AssistantPrefill})
+	}
+
+	req := &chatRequest{
+		Model:    model,
+		Messages: messages,
+		Stream:   stream,
+	}
+
+	switch opts.ResponseFormat {
+	case models.ResponseFormatJSON:
+		req.Format = "json"
+	case models.ResponseFormatJSONSchema:
+		req.Format = opts.ResponseSchema
+	}
+
+	for _, tool := range opts.Tools {
+		req.Tools = append(req.Tools, toolDefinition{
+			Type: "function",
+			Function: toolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+
+	options := map[string]any{}
+	if opts.MaxTokens > 0 {
+		options["num_predict"] = opts.MaxTokens
+	}
+	if opts.Temperature != nil {
+		options["temperature"] = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		options["top_p"] = *opts.TopP
+	}
+	if opts.Seed != nil {
+		options["seed"] = *opts.Seed
+	}
+	if opts.FrequencyPenalty != nil {
+		options["frequency_penalty"] = *opts.FrequencyPenalty
+	}
+	if opts.PresencePenalty != nil {
+		options["presence_penalty"] = *opts.PresencePenalty
+	}
+	if len(options) > 0 {
+		req.Options = options
+	}
+
+	return req, nil
+}
+
+// images returns the images of message, loaded and preprocessed, in the
+// base64 form the chat API expects.
+func (c *Client) images(ctx context.Context, message models.AIChatMessage) ([]string, error) {
+	images, err := util.LoadImagesWith(ctx, message, c.imageResolver)
+	if err != nil {
+		return nil, err
+	}
+	if c.convertHEIC {
+		if images, err = util.ConvertHEICImages(images, c.jpegQuality); err != nil {
+			return nil, err
+		}
+	}
+	images = util.PreprocessImages(images, c.maxImageDim, c.jpegQuality)
+
+	var encoded []string
+	for _, image := range images {
+		encoded = append(encoded, base64.StdEncoding.EncodeToString(image.Bytes))
+	}
+	return encoded, nil
+}
+
+// post sends a JSON request to path and returns the response once a successful
+// status has been received. The caller must close the response body.
+func (c *Client) post(ctx context.Context, path string, payload any) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ollama request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call ollama: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var result chatResponse
+		data, _ := io.ReadAll(resp.Body)
+		err := fmt.Errorf("ollama request failed: status code %d", resp.StatusCode)
+		if json.Unmarshal(data, &result) == nil && result.Error != "" {
+			err = fmt.Errorf("ollama request failed: status code %d: %s", resp.StatusCode, result.Error)
+		}
+		return nil, models.NewAIError("ollama", resp.StatusCode, err)
+	}
+
+	return resp, nil
+}
+
+// finishReason normalizes an Ollama done_reason.
+func finishReason(reason string) models.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "stop":
+		return models.FinishReasonStop
+	case "length":
+		return models.FinishReasonLength
+	}
+	return models.FinishReasonOther
+}
@@ -1,42 +1,359 @@
 package openai
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
 
 	"github.com/A-pen-app/ai-client/models"
 	"github.com/A-pen-app/ai-client/store"
+	"github.com/A-pen-app/ai-client/util"
 	"github.com/openai/openai-go/v2"
 	"github.com/openai/openai-go/v2/option"
 )
 
+// maxTemperature is the upper bound OpenAI accepts for temperature.
+const maxTemperature = 2.0
+
+// maxImages is the default AIClientOptions.MaxImages, the most images OpenAI
+// accepts in one request.
+const maxImages = 500
+
+// maxPenalty bounds the frequency and presence penalties OpenAI accepts.
+const maxPenalty = 2.0
+
 type Client struct {
 	client       *openai.Client
 	defaultModel openai.ChatModel
+	// httpClient is the caller-supplied HTTP client, if any.
+	httpClient *http.Client
+	// customURL is set when requests go to a base URL other than OpenAI's,
+	// whose models the OpenAI model registry does not describe.
+	customURL bool
+	// maxImageDim and jpegQuality configure image preprocessing; a zero
+	// maxImageDim disables it.
+	maxImageDim int
+	jpegQuality int
+	// convertHEIC converts HEIC images to JPEG before they are attached.
+	convertHEIC bool
+	// imageResolver fetches ImageUrls; nil uses util.HTTPImageResolver.
+	imageResolver util.ImageResolver
+	closed        atomic.Bool
 }
 
-func NewClient(apiKey string, model openai.ChatModel) (store.AIClient, error) {
+func NewClient(apiKey string, model openai.ChatModel, opts ...Option) (store.AIClient, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("openai API key cannot be empty")
 	}
 
-	client := openai.NewClient(option.WithAPIKey(apiKey))
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	userAgent := o.userAgent
+	if userAgent == "" {
+		userAgent = util.DefaultUserAgent()
+	}
+
+	requestOptions := []option.RequestOption{
+		option.WithAPIKey(apiKey),
+		option.WithHeader("User-Agent", userAgent),
+	}
+	if o.httpClient != nil {
+		requestOptions = append(requestOptions, option.WithHTTPClient(o.httpClient))
+	}
+	if o.baseURL != "" {
+		requestOptions = append(requestOptions, option.WithBaseURL(o.baseURL))
+	}
+	requestOptions = append(requestOptions, o.extra...)
+
+	client := openai.NewClient(requestOptions...)
 
 	if model == "" {
 		model = openai.ChatModelGPT4o
 	}
 
 	return &Client{
-		client:       &client,
-		defaultModel: model,
+		client:        &client,
+		defaultModel:  model,
+		httpClient:    o.httpClient,
+		customURL:     o.baseURL != "",
+		maxImageDim:   o.maxImageDim,
+		jpegQuality:   o.jpegQuality,
+		convertHEIC:   o.convertHEIC,
+		imageResolver: o.imageResolver,
 	}, nil
 }
 
 func (c *Client) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	text, _, err := c.GenerateWithUsage(ctx, message, opts)
+	return text, err
+}
+
+// GenerateChat replies to an ordered conversation, mapped onto a message
+// with models.NewChatMessage.
+func (c *Client) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+// GenerateWithUsage behaves like Generate and also reports token usage from
+// the completion response.
+func (c *Client) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	return result.Text, result.Usage, err
+}
+
+// GenerateWithTools returns the full result, including any tool calls the
+// model requested from opts.Tools.
+func (c *Client) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	ctx, cancel := util.WithRequestTimeout(ctx, opts.RequestTimeout)
+	defer cancel()
+
+	result, err := c.generate(ctx, message, opts)
+	return result, util.TimeoutError(ctx, err)
+}
+
+func (c *Client) generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
 	if c.client == nil {
-		return "", fmt.Errorf("openai client is not initialized")
+		return models.GenerateResult{}, fmt.Errorf("openai client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return models.GenerateResult{}, models.ErrClientClosed
+	}
+
+	params, err := c.buildParams(ctx, message, opts)
+	if err != nil {
+		return models.GenerateResult{}, err
+	}
+
+	resp, err := c.client.Chat.Completions.New(ctx, params, requestOptions(opts)...)
+	if err != nil {
+		return models.GenerateResult{}, wrapError(err)
+	}
+
+	result := models.GenerateResult{
+		Usage: models.Usage{
+			PromptTokens:       resp.Usage.PromptTokens,
+			CompletionTokens:   resp.Usage.CompletionTokens,
+			TotalTokens:        resp.Usage.TotalTokens,
+			CachedPromptTokens: resp.Usage.PromptTokensDetails.CachedTokens,
+		},
+	}
+	if opts.IncludeRawResponse {
+		result.Raw = json.RawMessage(resp.RawJSON())
+	}
+
+	if len(resp.Choices) == 0 {
+		return result, &models.AIError{Kind: models.ErrorKindEmptyResponse, Provider: "openai", Err: errors.New("empty response choices from OpenAI")}
+	}
+
+	if opts.N > 1 {
+		if len(resp.Choices) < opts.N {
+			return result, fmt.Errorf("openai returned %d candidates, requested %d", len(resp.Choices), opts.N)
+		}
+		for _, choice := range resp.Choices {
+			result.Candidates = append(result.Candidates, choice.Message.Content)
+		}
+	}
+
+	result.FinishReason = finishReason(resp.Choices[0].FinishReason)
+	if result.FinishReason == models.FinishReasonContentFilter {
+		return result, models.NewContentFilteredError("openai", resp.Choices[0].FinishReason)
+	}
+
+	choice := resp.Choices[0].Message
+	result.Text = choice.Content
+
+	if opts.LogProbs {
+		result.LogProbs = tokenLogProbs(resp.Choices[0].Logprobs.Content)
+		result.AvgLogProb = models.AverageLogProb(result.LogProbs)
+	}
+
+	for _, call := range choice.ToolCalls {
+		if call.Type != "function" {
+			continue
+		}
+		result.ToolCalls = append(result.ToolCalls, models.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: json.RawMessage(call.Function.Arguments),
+		})
+	}
+
+	return result, nil
+}
+
+// GenerateN returns every candidate requested with opts.N. With N <= 1 it
+// returns the single Generate result.
+func (c *Client) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Texts(), nil
+}
+
+// StreamGenerate streams the response text as it is produced. The returned
+// channel is closed once the stream ends; the last chunk has Done set and
+// carries any provider or context error.
+func (c *Client) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	ctx, cancel := util.WithRequestTimeout(ctx, opts.RequestTimeout)
+
+	ch, err := c.stream(ctx, message, opts, cancel)
+	if err != nil {
+		cancel()
+		return nil, util.TimeoutError(ctx, err)
+	}
+	return ch, nil
+}
+
+// stream starts the provider stream. On success the producing goroutine
+// owns cancel and calls it when the stream ends.
+func (c *Client) stream(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions, cancel context.CancelFunc) (<-chan models.StreamChunk, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("openai client is not initialized")
 	}
 
+	if c.closed.Load() {
+		return nil, models.ErrClientClosed
+	}
+
+	// Streams carry a single candidate and no log probabilities.
+	opts.N = 0
+	opts.LogProbs, opts.TopLogProbs = false, 0
+
+	params, err := c.buildParams(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := c.client.Chat.Completions.NewStreaming(ctx, params, requestOptions(opts)...)
+	if err := stream.Err(); err != nil {
+		stream.Close()
+		return nil, wrapError(err)
+	}
+
+	ch := make(chan models.StreamChunk)
+	go func() {
+		defer close(ch)
+		defer cancel()
+		defer stream.Close()
+
+		for stream.Next() {
+			chunk := stream.Current()
+			if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+				continue
+			}
+			if !util.SendChunk(ctx, ch, models.StreamChunk{Text: chunk.Choices[0].Delta.Content}) {
+				util.FinishStream(ctx, ch, nil)
+				return
+			}
+		}
+
+		util.FinishStream(ctx, ch, wrapError(stream.Err()))
+	}()
+
+	return ch, nil
+}
+
+// HealthCheck retrieves the default model, which verifies connectivity,
+// the API key and the model name without generating any tokens.
+func (c *Client) HealthCheck(ctx context.Context) error {
+	if c.client == nil {
+		return fmt.Errorf("openai client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return models.ErrClientClosed
+	}
+
+	if _, err := c.client.Models.Get(ctx, string(c.defaultModel)); err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
+
+// Close releases the idle connections of the HTTP client set with
+// WithHTTPClient and makes later calls fail with models.ErrClientClosed. It
+// is safe to call more than once.
+func (c *Client) Close() error {
+	c.closed.Store(true)
+	if c.httpClient != nil {
+		c.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// buildParams maps an AIChatMessage and options onto a chat completion request.
+func (c *Client) buildParams(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (openai.ChatCompletionNewParams, error) {
+	opts = util.AdaptMaxTokens(ctx, opts)
+
+	if err := opts.ValidateSampling(maxTemperature); err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+
+	if err := opts.ValidatePenalties(maxPenalty); err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+
+	if err := opts.ValidateResponseFormat(); err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+
+	if err := opts.ValidateImageDetail(); err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+
+	if err := opts.ValidateReasoningEffort(); err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+
+	if err := opts.ValidateLogitBias(); err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+
+	if err := opts.ValidateLogProbs(); err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+
+	if err := message.ValidateHistory(); err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+
+	if err := opts.ValidateImageCount("openai", message, maxImages); err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+
+	if len(message.DocumentUrls) > 0 {
+		return openai.ChatCompletionNewParams{}, fmt.Errorf("document input is not supported by openai chat; convert the documents to images or use the gemini client")
+	}
+
+	if message.AssistantPrefill != "" {
+		return openai.ChatCompletionNewParams{}, fmt.Errorf("assistant prefill is not supported by openai chat; use ResponseFormat instead")
+	}
+
+	if !c.customURL {
+		if err := opts.ValidateModel("openai"); err != nil {
+			return openai.ChatCompletionNewParams{}, err
+		}
+	}
+
+	message = util.FoldSystemPrompt(message, opts)
+
 	model := c.defaultModel
 	if opts.Model != "" {
 		model = openai.ChatModel(opts.Model)
@@ -48,10 +365,25 @@ func (c *Client) Generate(ctx context.Context, message models.AIChatMessage, opt
 		userContentParts = append(userContentParts, openai.TextContentPart(message.Text))
 	}
 
-	for _, url := range message.ImageUrls {
-		userContentParts = append(userContentParts, openai.ImageContentPart(
-			openai.ChatCompletionContentPartImageImageURLParam{
-				URL: url,
+	imageParts, err := c.imageParts(ctx, message, opts.ImageDetail)
+	if err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+	userContentParts = append(userContentParts, imageParts...)
+
+	audio, err := util.LoadAudio(ctx, message)
+	if err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+	for _, clip := range audio {
+		format, err := audioFormat(clip.ContentType())
+		if err != nil {
+			return openai.ChatCompletionNewParams{}, err
+		}
+		userContentParts = append(userContentParts, openai.InputAudioContentPart(
+			openai.ChatCompletionContentPartInputAudioInputAudioParam{
+				Data:   base64.StdEncoding.EncodeToString(clip.Bytes),
+				Format: format,
 			},
 		))
 	}
@@ -60,6 +392,28 @@ func (c *Client) Generate(ctx context.Context, message models.AIChatMessage, opt
 	if message.SystemPrompt != "" {
 		messages = append(messages, openai.SystemMessage(message.SystemPrompt))
 	}
+	for _, turn := range message.History {
+		switch turn.Role {
+		case models.RoleUser:
+			if !turn.HasImages() {
+				messages = append(messages, openai.UserMessage(turn.Text))
+				break
+			}
+			var parts []openai.ChatCompletionContentPartUnionParam
+			if turn.Text != "" {
+				parts = append(parts, openai.TextContentPart(turn.Text))
+			}
+			imageParts, err := c.imageParts(ctx, turn.ImageMessage(message.ImageMimeType), opts.ImageDetail)
+			if err != nil {
+				return openai.ChatCompletionNewParams{}, err
+			}
+			messages = append(messages, openai.UserMessage(append(parts, imageParts...)))
+		case models.RoleAssistant:
+			messages = append(messages, openai.AssistantMessage(turn.Text))
+		default:
+			return openai.ChatCompletionNewParams{}, fmt.Errorf("unsupported turn role %q", turn.Role)
+		}
+	}
 	messages = append(messages, openai.UserMessage(userContentParts))
 
 	params := openai.ChatCompletionNewParams{
@@ -67,24 +421,400 @@ func (c *Client) Generate(ctx context.Context, message models.AIChatMessage, opt
 		Messages: messages,
 	}
 
-	if opts.ResponseFormat == models.ResponseFormatJSON {
+	switch opts.ResponseFormat {
+	case models.ResponseFormatJSON:
 		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
 			OfJSONObject: &openai.ResponseFormatJSONObjectParam{},
 		}
+	case models.ResponseFormatJSONSchema:
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   "response",
+					Schema: opts.ResponseSchema,
+					Strict: openai.Bool(true),
+				},
+			},
+		}
+	}
+
+	reasoning := isReasoningModel(string(model))
+
+	if opts.ReasoningEffort != "" {
+		if reasoning {
+			params.ReasoningEffort = openai.ReasoningEffort(opts.ReasoningEffort)
+		} else if err := opts.UnsupportedReasoning("openai", string(model)); err != nil {
+			return openai.ChatCompletionNewParams{}, err
+		}
 	}
 
 	if opts.MaxTokens > 0 {
-		params.MaxTokens = openai.Int(opts.MaxTokens)
+		if reasoning {
+			params.MaxCompletionTokens = openai.Int(opts.MaxTokens)
+		} else {
+			params.MaxTokens = openai.Int(opts.MaxTokens)
+		}
+	}
+
+	for _, tool := range opts.Tools {
+		var parameters openai.FunctionParameters
+		if len(tool.Parameters) > 0 {
+			if err := json.Unmarshal(tool.Parameters, &parameters); err != nil {
+				return openai.ChatCompletionNewParams{}, fmt.Errorf("invalid parameters schema for tool %q: %w", tool.Name, err)
+			}
+		}
+		function := openai.FunctionDefinitionParam{
+			Name:       tool.Name,
+			Parameters: parameters,
+		}
+		if tool.Description != "" {
+			function.Description = openai.String(tool.Description)
+		}
+		params.Tools = append(params.Tools, openai.ChatCompletionFunctionTool(function))
+	}
+
+	// Reasoning models reject sampling parameters, so they are dropped.
+	if opts.Temperature != nil && !reasoning {
+		params.Temperature = openai.Float(*opts.Temperature)
+	}
+
+	if opts.TopP != nil && !reasoning {
+		params.TopP = openai.Float(*opts.TopP)
+	}
+
+	if opts.Seed != nil {
+		params.Seed = openai.Int(*opts.Seed)
+	}
+
+	if opts.N > 1 {
+		params.N = openai.Int(int64(opts.N))
+	}
+
+	if opts.FrequencyPenalty != nil && !reasoning {
+		params.FrequencyPenalty = openai.Float(*opts.FrequencyPenalty)
+	}
+
+	if opts.PresencePenalty != nil && !reasoning {
+		params.PresencePenalty = openai.Float(*opts.PresencePenalty)
+	}
+
+	// Reasoning models reject logit_bias like the sampling parameters.
+	if len(opts.LogitBias) > 0 {
+		if reasoning {
+			if err := opts.UnsupportedLogitBias(fmt.Sprintf("openai reasoning model %q", model)); err != nil {
+				return openai.ChatCompletionNewParams{}, err
+			}
+		} else {
+			params.LogitBias = make(map[string]int64, len(opts.LogitBias))
+			for token, bias := range opts.LogitBias {
+				params.LogitBias[token] = int64(bias)
+			}
+		}
+	}
+
+	if opts.LogProbs {
+		if reasoning {
+			return openai.ChatCompletionNewParams{}, fmt.Errorf("logprobs are not supported by openai reasoning model %q", model)
+		}
+		params.Logprobs = openai.Bool(true)
+		if opts.TopLogProbs > 0 {
+			params.TopLogprobs = openai.Int(int64(opts.TopLogProbs))
+		}
+	}
+
+	return params, nil
+}
+
+// BuildRequest returns the openai.ChatCompletionNewParams Generate would
+// send for message and opts, with images downloaded and preprocessed as
+// configured, without calling the API. Per-request headers, such as the
+// Idempotency-Key, are not part of it. The result can be passed to
+// GenerateRaw.
+func (c *Client) BuildRequest(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (any, error) {
+	return c.buildParams(ctx, message, opts)
+}
+
+// GenerateRaw sends a chat completion request built by the caller,
+// bypassing the AIChatMessage mapping, and returns the first choice's text.
+// params must be an openai.ChatCompletionNewParams (or a pointer to one);
+// an empty Model uses the client's default. This is an escape hatch for
+// requests AIChatMessage cannot express and is specific to this client.
+func (c *Client) GenerateRaw(ctx context.Context, params any) (string, error) {
+	if c.client == nil {
+		return "", fmt.Errorf("openai client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return "", models.ErrClientClosed
 	}
 
-	resp, err := c.client.Chat.Completions.New(ctx, params)
+	var request openai.ChatCompletionNewParams
+	switch p := params.(type) {
+	case openai.ChatCompletionNewParams:
+		request = p
+	case *openai.ChatCompletionNewParams:
+		request = *p
+	default:
+		return "", fmt.Errorf("openai GenerateRaw expects openai.ChatCompletionNewParams, got %T", params)
+	}
+	if request.Model == "" {
+		request.Model = c.defaultModel
+	}
+
+	resp, err := c.client.Chat.Completions.New(ctx, request)
 	if err != nil {
-		return "", err
+		return "", wrapError(err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("empty response choices from OpenAI")
+		return "", &models.AIError{Kind: models.ErrorKindEmptyResponse, Provider: "openai", Err: errors.New("empty response choices from OpenAI")}
+	}
+	if finishReason(resp.Choices[0].FinishReason) == models.FinishReasonContentFilter {
+		return "", models.NewContentFilteredError("openai", resp.Choices[0].FinishReason)
 	}
-
 	return resp.Choices[0].Message.Content, nil
 }
+
+// Transcribe converts speech to text with the audio transcription API,
+// which accepts more formats than chat audio input. opts.Model selects the
+// transcription model (default: whisper-1); opts.Temperature is honored.
+func (c *Client) Transcribe(ctx context.Context, audio models.AudioData, opts models.AIClientOptions) (string, error) {
+	ctx, cancel := util.WithRequestTimeout(ctx, opts.RequestTimeout)
+	defer cancel()
+
+	text, err := c.transcribe(ctx, audio, opts)
+	return text, util.TimeoutError(ctx, err)
+}
+
+func (c *Client) transcribe(ctx context.Context, audio models.AudioData, opts models.AIClientOptions) (string, error) {
+	if c.client == nil {
+		return "", fmt.Errorf("openai client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return "", models.ErrClientClosed
+	}
+
+	if len(audio.Bytes) == 0 {
+		return "", fmt.Errorf("audio cannot be empty")
+	}
+
+	model := openai.AudioModelWhisper1
+	if opts.Model != "" {
+		model = openai.AudioModel(opts.Model)
+	}
+
+	contentType := audio.ContentType()
+	params := openai.AudioTranscriptionNewParams{
+		// The API infers the audio format from the file name.
+		File:  openai.File(bytes.NewReader(audio.Bytes), "audio"+audioExtension(contentType), contentType),
+		Model: model,
+	}
+	if opts.Temperature != nil {
+		params.Temperature = openai.Float(*opts.Temperature)
+	}
+
+	resp, err := c.client.Audio.Transcriptions.New(ctx, params)
+	if err != nil {
+		return "", wrapError(err)
+	}
+	return resp.Text, nil
+}
+
+// Moderate classifies content with the moderation endpoint
+// (omni-moderation-latest).
+func (c *Client) Moderate(ctx context.Context, content string) (models.ModerationResult, error) {
+	if c.client == nil {
+		return models.ModerationResult{}, fmt.Errorf("openai client is not initialized")
+	}
+
+	if c.closed.Load() {
+		return models.ModerationResult{}, models.ErrClientClosed
+	}
+
+	resp, err := c.client.Moderations.New(ctx, openai.ModerationNewParams{
+		Input: openai.ModerationNewParamsInputUnion{OfString: openai.String(content)},
+		Model: openai.ModerationModelOmniModerationLatest,
+	})
+	if err != nil {
+		return models.ModerationResult{}, wrapError(err)
+	}
+
+	if len(resp.Results) == 0 {
+		return models.ModerationResult{}, &models.AIError{Kind: models.ErrorKindEmptyResponse, Provider: "openai", Err: errors.New("empty moderation results from OpenAI")}
+	}
+	moderation := resp.Results[0]
+
+	result := models.ModerationResult{Flagged: moderation.Flagged}
+	if err := json.Unmarshal([]byte(moderation.CategoryScores.RawJSON()), &result.Scores); err != nil {
+		return models.ModerationResult{}, fmt.Errorf("failed to decode moderation scores: %w", err)
+	}
+
+	var categories map[string]bool
+	if err := json.Unmarshal([]byte(moderation.Categories.RawJSON()), &categories); err != nil {
+		return models.ModerationResult{}, fmt.Errorf("failed to decode moderation categories: %w", err)
+	}
+	for category, flagged := range categories {
+		if flagged {
+			result.Categories = append(result.Categories, category)
+		}
+	}
+	slices.Sort(result.Categories)
+
+	return result, nil
+}
+
+// requestOptions returns the per-call request options for opts.
+func requestOptions(opts models.AIClientOptions) []option.RequestOption {
+	var requestOptions []option.RequestOption
+	if opts.IdempotencyKey != "" {
+		requestOptions = append(requestOptions, option.WithHeader("Idempotency-Key", opts.IdempotencyKey))
+	}
+	return requestOptions
+}
+
+// reasoningModelPrefixes are the families of OpenAI reasoning models, which
+// take max_completion_tokens instead of max_tokens and reject sampling
+// parameters.
+var reasoningModelPrefixes = []string{"o1", "o3", "o4", "gpt-5"}
+
+// isReasoningModel reports whether model is an OpenAI reasoning model,
+// ignoring a gateway prefix such as "openai/". The gpt-5 chat models are
+// not reasoning models.
+func isReasoningModel(model string) bool {
+	model = model[strings.LastIndex(model, "/")+1:]
+	if strings.HasPrefix(model, "gpt-5-chat") {
+		return false
+	}
+	for _, prefix := range reasoningModelPrefixes {
+		if model == prefix || strings.HasPrefix(model, prefix+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// imageParts returns the images of message as content parts.
+func (c *Client) imageParts(ctx context.Context, message models.AIChatMessage, detail models.ImageDetail) ([]openai.ChatCompletionContentPartUnionParam, error) {
+	urls, err := c.imageURLs(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]openai.ChatCompletionContentPartUnionParam, 0, len(urls))
+	for _, url := range urls {
+		parts = append(parts, openai.ImageContentPart(
+			openai.ChatCompletionContentPartImageImageURLParam{
+				URL:    url,
+				Detail: string(detail),
+			},
+		))
+	}
+	return parts, nil
+}
+
+// imageURLs returns the URLs of the message's image parts. ImageUrls are
+// passed through for OpenAI to download, unless image preprocessing, HEIC
+// conversion or an ImageResolver is configured, in which case every image
+// is downloaded, converted or preprocessed as configured, and sent inline
+// as a data URL.
+func (c *Client) imageURLs(ctx context.Context, message models.AIChatMessage) ([]string, error) {
+	var urls []string
+	images := util.InlineImages(message)
+	// Without preprocessing, OpenAI fetches the URLs itself, unless a
+	// resolver must fetch them.
+	if c.maxImageDim > 0 || c.convertHEIC || c.imageResolver != nil {
+		var err error
+		images, err = util.LoadImagesWith(ctx, message, c.imageResolver)
+		if err != nil {
+			return nil, err
+		}
+		if c.convertHEIC {
+			if images, err = util.ConvertHEICImages(images, c.jpegQuality); err != nil {
+				return nil, err
+			}
+		}
+		images = util.PreprocessImages(images, c.maxImageDim, c.jpegQuality)
+	} else {
+		urls = append(urls, message.ImageUrls...)
+	}
+
+	for _, image := range images {
+		urls = append(urls, "data:"+image.ContentType()+";base64,"+base64.StdEncoding.EncodeToString(image.Bytes))
+	}
+	return urls, nil
+}
+
+// tokenLogProbs maps the log probabilities of a choice.
+func tokenLogProbs(content []openai.ChatCompletionTokenLogprob) []models.TokenLogProb {
+	tokens := make([]models.TokenLogProb, 0, len(content))
+	for _, logprob := range content {
+		token := models.TokenLogProb{Token: logprob.Token, LogProb: logprob.Logprob}
+		for _, top := range logprob.TopLogprobs {
+			token.TopLogProbs = append(token.TopLogProbs, models.TokenLogProb{Token: top.Token, LogProb: top.Logprob})
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// audioFormat maps a MIME type onto the two formats chat audio input accepts.
+func audioFormat(contentType string) (string, error) {
+	switch contentType {
+	case "audio/wav", "audio/wave", "audio/x-wav", "audio/vnd.wave":
+		return "wav", nil
+	case "audio/mpeg", "audio/mp3":
+		return "mp3", nil
+	}
+	return "", fmt.Errorf("openai chat audio input supports wav and mp3, got %q; use Transcribe for other formats", contentType)
+}
+
+// audioExtension returns the file extension the transcription API expects
+// for a MIME type.
+func audioExtension(contentType string) string {
+	switch contentType {
+	case "audio/wav", "audio/wave", "audio/x-wav", "audio/vnd.wave":
+		return ".wav"
+	case "audio/mpeg", "audio/mp3":
+		return ".mp3"
+	case "audio/mp4", "audio/x-m4a", "audio/m4a", "video/mp4":
+		return ".m4a"
+	case "audio/ogg", "application/ogg":
+		return ".ogg"
+	case "audio/webm", "video/webm":
+		return ".webm"
+	case "audio/flac", "audio/x-flac":
+		return ".flac"
+	}
+	if extensions, _ := mime.ExtensionsByType(contentType); len(extensions) > 0 {
+		return extensions[0]
+	}
+	return ""
+}
+
+// finishReason normalizes an OpenAI finish_reason.
+func finishReason(reason string) models.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "stop":
+		return models.FinishReasonStop
+	case "length":
+		return models.FinishReasonLength
+	case "content_filter":
+		return models.FinishReasonContentFilter
+	case "tool_calls", "function_call":
+		return models.FinishReasonToolCalls
+	}
+	return models.FinishReasonOther
+}
+
+// wrapError attaches the HTTP status of an OpenAI API error so callers can
+// classify it without depending on the openai package.
+func wrapError(err error) error {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return models.NewAIError("openai", apiErr.StatusCode, err)
+	}
+	return err
+}
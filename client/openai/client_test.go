@@ -0,0 +1,178 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/openai/openai-go/v2"
+)
+
+// newFakeServer returns a server that answers every chat completion with
+// response.
+func newFakeServer(t *testing.T, response string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestClient(t *testing.T, baseURL string, model string) *Client {
+	t.Helper()
+	client, err := NewClient("test-key", model, WithBaseURL(baseURL))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client.(*Client)
+}
+
+func TestGenerateContentFiltered(t *testing.T) {
+	srv := newFakeServer(t, `{
+		"id": "chatcmpl-1",
+		"object": "chat.completion",
+		"created": 1700000000,
+		"model": "gpt-4o",
+		"choices": [{
+			"index": 0,
+			"message": {"role": "assistant", "content": ""},
+			"finish_reason": "content_filter"
+		}]
+	}`)
+	client := newTestClient(t, srv.URL, "gpt-4o")
+
+	result, err := client.GenerateWithTools(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{})
+
+	var aiErr *models.AIError
+	if !errors.As(err, &aiErr) {
+		t.Fatalf("GenerateWithTools error = %v, want a *models.AIError", err)
+	}
+	if aiErr.Kind != models.ErrorKindContentFiltered {
+		t.Errorf("Kind = %q, want %q", aiErr.Kind, models.ErrorKindContentFiltered)
+	}
+	if aiErr.Reason != "content_filter" {
+		t.Errorf("Reason = %q, want %q", aiErr.Reason, "content_filter")
+	}
+	if result.FinishReason != models.FinishReasonContentFilter {
+		t.Errorf("FinishReason = %q, want %q", result.FinishReason, models.FinishReasonContentFilter)
+	}
+}
+
+func TestBuildRequestTokenLimitAndSampling(t *testing.T) {
+	tests := []struct {
+		model     string
+		reasoning bool
+	}{
+		{model: "gpt-4o", reasoning: false},
+		{model: "gpt-4.1-mini", reasoning: false},
+		{model: "o1", reasoning: true},
+		{model: "o3-mini", reasoning: true},
+		{model: "gpt-5", reasoning: true},
+		{model: "gpt-5-mini", reasoning: true},
+		{model: "gpt-5-chat-latest", reasoning: false},
+		{model: "openai/o3-mini", reasoning: true},
+	}
+
+	temperature := 0.2
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			client := newTestClient(t, "", tt.model)
+
+			built, err := client.BuildRequest(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{
+				MaxTokens:   256,
+				Temperature: &temperature,
+			})
+			if err != nil {
+				t.Fatalf("BuildRequest: %v", err)
+			}
+			params := built.(openai.ChatCompletionNewParams)
+
+			if tt.reasoning {
+				if params.MaxTokens.Valid() {
+					t.Errorf("max_tokens = %d, want it unset for a reasoning model", params.MaxTokens.Value)
+				}
+				if !params.MaxCompletionTokens.Valid() || params.MaxCompletionTokens.Value != 256 {
+					t.Errorf("max_completion_tokens = %v, want 256", params.MaxCompletionTokens)
+				}
+				if params.Temperature.Valid() {
+					t.Errorf("temperature = %v, want it unset for a reasoning model", params.Temperature.Value)
+				}
+				return
+			}
+
+			if !params.MaxTokens.Valid() || params.MaxTokens.Value != 256 {
+				t.Errorf("max_tokens = %v, want 256", params.MaxTokens)
+			}
+			if params.MaxCompletionTokens.Valid() {
+				t.Errorf("max_completion_tokens = %d, want it unset", params.MaxCompletionTokens.Value)
+			}
+			if !params.Temperature.Valid() || params.Temperature.Value != temperature {
+				t.Errorf("temperature = %v, want %v", params.Temperature, temperature)
+			}
+		})
+	}
+}
+
+func TestGenerateSeed(t *testing.T) {
+	// The fake answers with the sampling parameters it received, as a
+	// deterministic model would for the same seed and temperature.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Seed        *int64   `json:"seed"`
+			Temperature *float64 `json:"temperature"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Seed == nil || request.Temperature == nil {
+			http.Error(w, `{"error": {"message": "seed and temperature are required"}}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{
+			"id": "chatcmpl-1",
+			"object": "chat.completion",
+			"created": 1700000000,
+			"model": "gpt-4o",
+			"choices": [{
+				"index": 0,
+				"message": {"role": "assistant", "content": "seed %d temperature %g"},
+				"finish_reason": "stop"
+			}]
+		}`, *request.Seed, *request.Temperature)
+	}))
+	defer srv.Close()
+	client := newTestClient(t, srv.URL, "gpt-4o")
+
+	seed, temperature := int64(42), 0.0
+	opts := models.AIClientOptions{Seed: &seed, Temperature: &temperature}
+	message := models.AIChatMessage{Text: "Read the name on this card."}
+
+	first, err := client.Generate(context.Background(), message, opts)
+	if err != nil {
+		t.Fatalf("first Generate: %v", err)
+	}
+	second, err := client.Generate(context.Background(), message, opts)
+	if err != nil {
+		t.Fatalf("second Generate: %v", err)
+	}
+	if first != second {
+		t.Errorf("Generate returned %q and then %q, want identical text", first, second)
+	}
+	if want := "seed 42 temperature 0"; first != want {
+		t.Errorf("Generate = %q, want %q", first, want)
+	}
+
+	built, err := client.BuildRequest(context.Background(), message, opts)
+	if err != nil {
+		t.Fatalf("BuildRequest: %v", err)
+	}
+	if params := built.(openai.ChatCompletionNewParams); params.Seed.Value != seed {
+		t.Errorf("seed = %v, want %d", params.Seed, seed)
+	}
+}
@@ -0,0 +1,99 @@
+package openai
+
+import (
+	"net/http"
+
+	"github.com/openai/openai-go/v2/option"
+
+	"github.com/A-pen-app/ai-client/util"
+)
+
+// Option configures a Client created by NewClient.
+type Option func(*options)
+
+type options struct {
+	httpClient    *http.Client
+	baseURL       string
+	extra         []option.RequestOption
+	userAgent     string
+	maxImageDim   int
+	jpegQuality   int
+	convertHEIC   bool
+	imageResolver util.ImageResolver
+}
+
+// WithHTTPClient sends requests through client, e.g. to route them via a
+// proxy or tune connection pooling.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithBaseURL sends requests to baseURL instead of the default OpenAI
+// endpoint, e.g. an internal gateway such as LiteLLM or an OpenAI-compatible
+// provider. An empty baseURL keeps the default endpoint.
+func WithBaseURL(baseURL string) Option {
+	return func(o *options) {
+		o.baseURL = baseURL
+	}
+}
+
+// WithRequestOptions applies SDK request options, such as
+// option.WithHeader, to every request. They are applied after the client's
+// own options and may override them.
+func WithRequestOptions(requestOptions ...option.RequestOption) Option {
+	return func(o *options) {
+		o.extra = append(o.extra, requestOptions...)
+	}
+}
+
+// WithHeaders sends headers with every request, including streaming ones,
+// e.g. a tenant ID or the authentication header of a gateway.
+func WithHeaders(headers map[string]string) Option {
+	return func(o *options) {
+		for key, value := range headers {
+			o.extra = append(o.extra, option.WithHeader(key, value))
+		}
+	}
+}
+
+// WithUserAgent sends userAgent as the User-Agent of every request,
+// including streaming ones, instead of util.DefaultUserAgent. A User-Agent
+// set with WithHeaders takes precedence.
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithImagePreprocessing downscales images whose longer side exceeds maxDim
+// pixels and re-encodes them as JPEG at jpegQuality (1-100, default 85)
+// before they are attached, see util.PreprocessImage. High-resolution photos
+// then cost fewer tokens with no loss in OCR accuracy.
+func WithImagePreprocessing(maxDim int, jpegQuality int) Option {
+	return func(o *options) {
+		o.maxImageDim = maxDim
+		o.jpegQuality = jpegQuality
+	}
+}
+
+// WithHEICConversion converts HEIC images, such as iPhone photos, to JPEG
+// before they are attached, since most providers reject HEIC. It needs a
+// binary built with -tags heic; otherwise requests with HEIC images fail
+// with util.ErrHEICUnsupported. The JPEG quality is the one set with
+// WithImagePreprocessing, or 85.
+func WithHEICConversion() Option {
+	return func(o *options) {
+		o.convertHEIC = true
+	}
+}
+
+// WithImageResolver fetches ImageUrls with resolver instead of
+// util.HTTPImageResolver, e.g. to read object-store keys or sign URLs
+// before downloading them.
+func WithImageResolver(resolver util.ImageResolver) Option {
+	return func(o *options) {
+		o.imageResolver = resolver
+	}
+}
@@ -0,0 +1,112 @@
+// Package openaicompat is a client for providers that serve the OpenAI chat
+// completions API, such as Groq, Together, Fireworks or a local vLLM
+// server. It reuses the OpenAI client's request and response mapping.
+package openaicompat
+
+import (
+	"fmt"
+	"net/http"
+
+	aiopenai "github.com/A-pen-app/ai-client/client/openai"
+	"github.com/A-pen-app/ai-client/store"
+	"github.com/A-pen-app/ai-client/util"
+	openaiSDK "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+// placeholderAPIKey is sent when no API key is given, for servers such as
+// vLLM that do not check it.
+const placeholderAPIKey = "EMPTY"
+
+// Option configures a client created by NewClient.
+type Option func(*options)
+
+type options struct {
+	httpClient    *http.Client
+	headers       [][2]string
+	maxImageDim   int
+	jpegQuality   int
+	convertHEIC   bool
+	imageResolver util.ImageResolver
+}
+
+// WithHeader sends a header with every request, e.g. a provider-specific
+// authentication or routing header.
+func WithHeader(key string, value string) Option {
+	return func(o *options) {
+		o.headers = append(o.headers, [2]string{key, value})
+	}
+}
+
+// WithHTTPClient sends requests through client, e.g. to route them via a
+// proxy or tune connection pooling.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithImagePreprocessing downscales large images before they are attached,
+// see openai.WithImagePreprocessing.
+func WithImagePreprocessing(maxDim int, jpegQuality int) Option {
+	return func(o *options) {
+		o.maxImageDim = maxDim
+		o.jpegQuality = jpegQuality
+	}
+}
+
+// WithHEICConversion converts HEIC images to JPEG before they are attached,
+// see openai.WithHEICConversion.
+func WithHEICConversion() Option {
+	return func(o *options) {
+		o.convertHEIC = true
+	}
+}
+
+// WithImageResolver fetches ImageUrls with resolver, see
+// openai.WithImageResolver.
+func WithImageResolver(resolver util.ImageResolver) Option {
+	return func(o *options) {
+		o.imageResolver = resolver
+	}
+}
+
+// NewClient creates a client for the OpenAI-compatible endpoint at baseURL,
+// e.g. "https://api.groq.com/openai/v1". model is required, as there is no
+// default that every provider serves. An empty apiKey is allowed for
+// servers without authentication.
+func NewClient(baseURL string, apiKey string, model string, opts ...Option) (store.AIClient, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("openaicompat base URL cannot be empty")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("openaicompat model cannot be empty")
+	}
+	if apiKey == "" {
+		apiKey = placeholderAPIKey
+	}
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	clientOpts := []aiopenai.Option{aiopenai.WithBaseURL(baseURL)}
+	if o.httpClient != nil {
+		clientOpts = append(clientOpts, aiopenai.WithHTTPClient(o.httpClient))
+	}
+	if o.maxImageDim > 0 {
+		clientOpts = append(clientOpts, aiopenai.WithImagePreprocessing(o.maxImageDim, o.jpegQuality))
+	}
+	if o.convertHEIC {
+		clientOpts = append(clientOpts, aiopenai.WithHEICConversion())
+	}
+	if o.imageResolver != nil {
+		clientOpts = append(clientOpts, aiopenai.WithImageResolver(o.imageResolver))
+	}
+	for _, header := range o.headers {
+		clientOpts = append(clientOpts, aiopenai.WithRequestOptions(option.WithHeader(header[0], header[1])))
+	}
+
+	return aiopenai.NewClient(apiKey, openaiSDK.ChatModel(model), clientOpts...)
+}
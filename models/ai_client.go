@@ -1,9 +1,145 @@
 package models
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
 type AIChatMessage struct {
 	SystemPrompt string
 	Text         string
 	ImageUrls    []string
+	// Images carries image bytes already held in memory. They are sent
+	// after any ImageUrls.
+	Images []ImageData
+	// ImageMimeType, e.g. "image/heic", is used instead of detection for
+	// downloaded ImageUrls and for Images without their own MimeType. Set
+	// it when every image is known to have that type.
+	ImageMimeType string
+	// History holds earlier conversation turns, oldest first. They are sent
+	// before the current user message.
+	History []Turn
+	// AudioUrls and Audio carry audio clips for providers with chat audio
+	// input (Gemini, and OpenAI audio models for wav or mp3). Audio is sent
+	// after any AudioUrls.
+	AudioUrls []string
+	Audio     []AudioData
+	// DocumentUrls links to documents such as PDFs, which Gemini reads
+	// directly, text layer included. Other providers reject them.
+	DocumentUrls []string
+	// AssistantPrefill starts the assistant's reply, e.g. "{" to force a
+	// bare JSON object. The model continues from it and the returned text
+	// includes it. Anthropic, Bedrock and Ollama support it; OpenAI and
+	// Gemini reject it. Anthropic rejects a prefill ending in whitespace.
+	AssistantPrefill string
+}
+
+// HasAudio reports whether the message carries any audio.
+func (m AIChatMessage) HasAudio() bool {
+	return len(m.AudioUrls) > 0 || len(m.Audio) > 0
+}
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	// RoleSystem is only valid in a ChatTurn.
+	RoleSystem Role = "system"
+)
+
+// Turn is a single earlier message in a conversation. Only user turns may
+// carry images; they are loaded and preprocessed like the images of the
+// current message.
+type Turn struct {
+	Role      Role
+	Text      string
+	ImageUrls []string
+	Images    []ImageData
+}
+
+// HasImages reports whether the turn carries any images.
+func (t Turn) HasImages() bool {
+	return len(t.ImageUrls) > 0 || len(t.Images) > 0
+}
+
+// ImageMessage returns a message carrying only the turn's images, with
+// imageMimeType as their default MIME type, so that they can be loaded like
+// the images of the current message.
+func (t Turn) ImageMessage(imageMimeType string) AIChatMessage {
+	return AIChatMessage{
+		ImageUrls:     t.ImageUrls,
+		Images:        t.Images,
+		ImageMimeType: imageMimeType,
+	}
+}
+
+// ValidateHistory checks that every turn in History is a user or assistant
+// turn and that only user turns carry images.
+func (m AIChatMessage) ValidateHistory() error {
+	for i, turn := range m.History {
+		switch turn.Role {
+		case RoleUser:
+		case RoleAssistant:
+			if turn.HasImages() {
+				return fmt.Errorf("history turn %d: images are only supported in user turns", i)
+			}
+		default:
+			return fmt.Errorf("history turn %d: unsupported turn role %q", i, turn.Role)
+		}
+	}
+	return nil
+}
+
+// ImageCount returns the number of images the message carries, those of
+// its History included.
+func (m AIChatMessage) ImageCount() int {
+	count := len(m.ImageUrls) + len(m.Images)
+	for _, turn := range m.History {
+		count += len(turn.ImageUrls) + len(turn.Images)
+	}
+	return count
+}
+
+// ImageData is an inline image. MimeType is optional and detected from the
+// bytes when empty; set it for formats sniffing does not recognize, such as
+// HEIC.
+type ImageData struct {
+	Bytes    []byte
+	MimeType string
+}
+
+// ContentType returns MimeType, falling back to sniffing the image bytes.
+func (d ImageData) ContentType() string {
+	if d.MimeType != "" {
+		return d.MimeType
+	}
+	return http.DetectContentType(d.Bytes)
+}
+
+// AudioData is an inline audio clip. MimeType is optional and detected from
+// the bytes when empty.
+type AudioData struct {
+	Bytes    []byte
+	MimeType string
+}
+
+// ContentType returns MimeType, falling back to sniffing the audio bytes.
+func (d AudioData) ContentType() string {
+	if d.MimeType != "" {
+		return d.MimeType
+	}
+	return http.DetectContentType(d.Bytes)
+}
+
+// DocumentData is a downloaded document, e.g. a PDF.
+type DocumentData struct {
+	Bytes    []byte
+	MimeType string
 }
 
 type ResponseFormat string
@@ -11,10 +147,534 @@ type ResponseFormat string
 const (
 	ResponseFormatJSON ResponseFormat = "json"
 	ResponseFormatText ResponseFormat = "text"
+	// ResponseFormatJSONSchema requests strict structured output matching
+	// AIClientOptions.ResponseSchema.
+	ResponseFormatJSONSchema ResponseFormat = "json_schema"
+)
+
+// ImageDetail trades image fidelity for cost. The empty value means
+// ImageDetailAuto.
+type ImageDetail string
+
+const (
+	ImageDetailAuto ImageDetail = "auto"
+	ImageDetailLow  ImageDetail = "low"
+	ImageDetailHigh ImageDetail = "high"
+)
+
+// ReasoningEffort sets how much a reasoning-capable model thinks before
+// answering. The empty value leaves the provider default.
+type ReasoningEffort string
+
+const (
+	ReasoningEffortLow    ReasoningEffort = "low"
+	ReasoningEffortMedium ReasoningEffort = "medium"
+	ReasoningEffortHigh   ReasoningEffort = "high"
 )
 
+// ErrReasoningUnsupported is returned, wrapped, when ReasoningEffort is set
+// with StrictReasoning for a model that does not support it.
+var ErrReasoningUnsupported = errors.New("reasoning effort is not supported")
+
+// ErrLogitBiasUnsupported is returned, wrapped, when LogitBias is set with
+// StrictLogitBias for a provider that does not support it.
+var ErrLogitBiasUnsupported = errors.New("logit bias is not supported")
+
+// ErrTooManyImages is returned, wrapped in an *AIError of kind
+// ErrorKindInvalidRequest, when a message carries more images than
+// AIClientOptions.MaxImages or the provider's limit allows.
+var ErrTooManyImages = errors.New("too many images")
+
+// MaxTopLogProbs is the largest AIClientOptions.TopLogProbs providers
+// accept.
+const MaxTopLogProbs = 20
+
+// MaxLogitBias bounds the values of AIClientOptions.LogitBias: each must be
+// within [-MaxLogitBias, MaxLogitBias].
+const MaxLogitBias = 100
+
+// SafetySetting sets the blocking threshold for one harm category, using
+// Gemini's names, e.g. Category "HARM_CATEGORY_DANGEROUS_CONTENT" with
+// Threshold "BLOCK_ONLY_HIGH".
+type SafetySetting struct {
+	Category  string
+	Threshold string
+}
+
 type AIClientOptions struct {
 	MaxTokens      int64
 	Model          string
 	ResponseFormat ResponseFormat
+	// Temperature and TopP are left to the provider default when nil.
+	Temperature *float64
+	TopP        *float64
+	// ResponseSchema is the JSON Schema the response must follow. It is
+	// required when ResponseFormat is ResponseFormatJSONSchema.
+	ResponseSchema json.RawMessage
+	// Tools lists the functions the model may call instead of answering
+	// directly. Use GenerateWithTools to receive the resulting calls.
+	Tools []Tool
+	// NoCache bypasses a caching client for this call.
+	NoCache bool
+	// CacheTTL overrides how long a caching client keeps this response.
+	CacheTTL time.Duration
+	// RequestTimeout bounds a single call independently of the caller's
+	// context. Zero means no extra timeout. When it fires the returned error
+	// matches ErrRequestTimeout.
+	RequestTimeout time.Duration
+	// ImageDetail sets the resolution images are processed at. OpenAI maps
+	// it to the image detail and Gemini to the media resolution; other
+	// providers ignore it.
+	ImageDetail ImageDetail
+	// Seed requests deterministic sampling where the provider supports it.
+	// Anthropic and Bedrock reject it.
+	Seed *int64
+	// N is the number of candidate completions to request. Values of 1 or
+	// less request a single completion. Only OpenAI and Gemini support more
+	// than one, and streaming always returns one.
+	N int
+	// FrequencyPenalty and PresencePenalty discourage repetition. They are
+	// left to the provider default when nil; Anthropic and Bedrock reject them.
+	FrequencyPenalty *float64
+	PresencePenalty  *float64
+	// SafetySettings overrides Gemini's default safety filters. Other
+	// providers ignore it.
+	SafetySettings []SafetySetting
+	// SystemAsUser prepends the SystemPrompt to the first user message
+	// instead of sending it in the provider's system field, for models that
+	// ignore the system role.
+	SystemAsUser bool
+	// AllowUnknownModels skips checking Model against the provider's model
+	// registry, so a new model can be used before the registry lists it.
+	AllowUnknownModels bool
+	// IncludeRawResponse keeps the provider's response body in
+	// GenerateResult.Raw, e.g. for audit logs. It is off by default to avoid
+	// holding on to the extra bytes.
+	IncludeRawResponse bool
+	// ReasoningEffort maps to OpenAI's reasoning effort and Gemini's
+	// thinking configuration. Models without reasoning ignore it unless
+	// StrictReasoning is set.
+	ReasoningEffort ReasoningEffort
+	// StrictReasoning makes an unsupported ReasoningEffort an error
+	// matching ErrReasoningUnsupported instead of being ignored.
+	StrictReasoning bool
+	// ThinkingBudget caps the tokens Gemini 2.5 models spend thinking; 0
+	// disables thinking for faster responses. It takes precedence over
+	// ReasoningEffort. Nil leaves the provider default, and other providers
+	// ignore it.
+	ThinkingBudget *int
+	// IncludeThoughts asks Gemini for summaries of its thinking, returned in
+	// GenerateResult.Thoughts apart from the answer. Other providers ignore
+	// it.
+	IncludeThoughts bool
+	// IdempotencyKey is sent to OpenAI as the Idempotency-Key header so that
+	// a retried request is not processed, and billed, twice. The retrying
+	// client sets one when it is empty. Other providers have no equivalent
+	// and ignore it.
+	IdempotencyKey string
+	// AdaptiveTokens lowers MaxTokens when the context deadline leaves too
+	// little time to generate it, trading a shorter answer for a timeout.
+	// See util.AdaptMaxTokens for the heuristic.
+	AdaptiveTokens bool
+	// LogitBias maps token IDs, as decimal strings, to a bias from -100 to
+	// 100 that is added to their logits, e.g. -100 to ban a token. Token IDs
+	// depend on the model's tokenizer. Only OpenAI supports it; other
+	// providers ignore it unless StrictLogitBias is set.
+	LogitBias map[string]int
+	// StrictLogitBias makes an unsupported LogitBias an error matching
+	// ErrLogitBiasUnsupported instead of being ignored.
+	StrictLogitBias bool
+	// LogProbs returns the log probability of each generated token in
+	// GenerateResult.LogProbs, e.g. to score confidence without asking the
+	// model to report it. OpenAI, except its reasoning models, and Gemini
+	// support it; other providers return an error. Streams do not return
+	// log probabilities.
+	LogProbs bool
+	// TopLogProbs also returns up to this many of the most likely tokens at
+	// each position, at most MaxTopLogProbs. It requires LogProbs.
+	TopLogProbs int
+	// CachedContent names a Gemini cached content holding the message's
+	// system prompt, created with the Gemini client's CacheSystemPrompt or
+	// PromptCache, so the prompt is billed at the cached rate instead of
+	// being sent with every call. Gemini sends the system prompt inline
+	// instead when tools are set. Other providers ignore it and always send
+	// the system prompt inline, so keep SystemPrompt set.
+	CachedContent string
+	// MaxImages caps the images of a message, History included, replacing
+	// the provider's default limit: 500 for OpenAI, 3000 for Gemini, 100 for
+	// Anthropic and 20 for Bedrock; Ollama has none. The call fails before
+	// anything is downloaded or sent. 0 keeps the provider default and a
+	// negative value disables the check.
+	MaxImages int
+}
+
+// WithDefaults returns o with every zero-value field taken from defaults,
+// so per-call options override store-wide defaults field by field.
+// IdempotencyKey identifies a single request and is never defaulted.
+func (o AIClientOptions) WithDefaults(defaults AIClientOptions) AIClientOptions {
+	if o.MaxTokens == 0 {
+		o.MaxTokens = defaults.MaxTokens
+	}
+	if o.Model == "" {
+		o.Model = defaults.Model
+	}
+	if o.ResponseFormat == "" {
+		o.ResponseFormat = defaults.ResponseFormat
+	}
+	if o.Temperature == nil {
+		o.Temperature = defaults.Temperature
+	}
+	if o.TopP == nil {
+		o.TopP = defaults.TopP
+	}
+	if o.ResponseSchema == nil {
+		o.ResponseSchema = defaults.ResponseSchema
+	}
+	if o.Tools == nil {
+		o.Tools = defaults.Tools
+	}
+	if !o.NoCache {
+		o.NoCache = defaults.NoCache
+	}
+	if o.CacheTTL == 0 {
+		o.CacheTTL = defaults.CacheTTL
+	}
+	if o.RequestTimeout == 0 {
+		o.RequestTimeout = defaults.RequestTimeout
+	}
+	if o.ImageDetail == "" {
+		o.ImageDetail = defaults.ImageDetail
+	}
+	if o.Seed == nil {
+		o.Seed = defaults.Seed
+	}
+	if o.N == 0 {
+		o.N = defaults.N
+	}
+	if o.FrequencyPenalty == nil {
+		o.FrequencyPenalty = defaults.FrequencyPenalty
+	}
+	if o.PresencePenalty == nil {
+		o.PresencePenalty = defaults.PresencePenalty
+	}
+	if o.SafetySettings == nil {
+		o.SafetySettings = defaults.SafetySettings
+	}
+	if !o.SystemAsUser {
+		o.SystemAsUser = defaults.SystemAsUser
+	}
+	if !o.AllowUnknownModels {
+		o.AllowUnknownModels = defaults.AllowUnknownModels
+	}
+	if !o.IncludeRawResponse {
+		o.IncludeRawResponse = defaults.IncludeRawResponse
+	}
+	if o.ReasoningEffort == "" {
+		o.ReasoningEffort = defaults.ReasoningEffort
+	}
+	if !o.StrictReasoning {
+		o.StrictReasoning = defaults.StrictReasoning
+	}
+	if o.ThinkingBudget == nil {
+		o.ThinkingBudget = defaults.ThinkingBudget
+	}
+	if !o.IncludeThoughts {
+		o.IncludeThoughts = defaults.IncludeThoughts
+	}
+	if !o.AdaptiveTokens {
+		o.AdaptiveTokens = defaults.AdaptiveTokens
+	}
+	if o.LogitBias == nil {
+		o.LogitBias = defaults.LogitBias
+	}
+	if !o.StrictLogitBias {
+		o.StrictLogitBias = defaults.StrictLogitBias
+	}
+	if !o.LogProbs {
+		o.LogProbs = defaults.LogProbs
+	}
+	if o.TopLogProbs == 0 {
+		o.TopLogProbs = defaults.TopLogProbs
+	}
+	if o.CachedContent == "" {
+		o.CachedContent = defaults.CachedContent
+	}
+	if o.MaxImages == 0 {
+		o.MaxImages = defaults.MaxImages
+	}
+	return o
+}
+
+// ValidateSampling checks Temperature and TopP against the accepted ranges.
+// maxTemperature is the provider-specific upper bound for Temperature.
+func (o AIClientOptions) ValidateSampling(maxTemperature float64) error {
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > maxTemperature) {
+		return fmt.Errorf("temperature %v is out of range [0, %v]", *o.Temperature, maxTemperature)
+	}
+	if o.TopP != nil && (*o.TopP < 0 || *o.TopP > 1) {
+		return fmt.Errorf("top_p %v is out of range [0, 1]", *o.TopP)
+	}
+	return nil
+}
+
+// ValidatePenalties checks FrequencyPenalty and PresencePenalty against
+// the provider-specific range [-maxPenalty, maxPenalty].
+func (o AIClientOptions) ValidatePenalties(maxPenalty float64) error {
+	if o.FrequencyPenalty != nil && (*o.FrequencyPenalty < -maxPenalty || *o.FrequencyPenalty > maxPenalty) {
+		return fmt.Errorf("frequency_penalty %v is out of range [%v, %v]", *o.FrequencyPenalty, -maxPenalty, maxPenalty)
+	}
+	if o.PresencePenalty != nil && (*o.PresencePenalty < -maxPenalty || *o.PresencePenalty > maxPenalty) {
+		return fmt.Errorf("presence_penalty %v is out of range [%v, %v]", *o.PresencePenalty, -maxPenalty, maxPenalty)
+	}
+	return nil
+}
+
+// ValidateResponseFormat checks that a schema is supplied when structured
+// output is requested.
+func (o AIClientOptions) ValidateResponseFormat() error {
+	if o.ResponseFormat != ResponseFormatJSONSchema {
+		return nil
+	}
+	if len(o.ResponseSchema) == 0 {
+		return fmt.Errorf("response schema cannot be empty when response format is %q", ResponseFormatJSONSchema)
+	}
+	if !json.Valid(o.ResponseSchema) {
+		return fmt.Errorf("response schema is not valid JSON")
+	}
+	return nil
+}
+
+// ValidateImageDetail checks that ImageDetail is a known value.
+func (o AIClientOptions) ValidateImageDetail() error {
+	switch o.ImageDetail {
+	case "", ImageDetailAuto, ImageDetailLow, ImageDetailHigh:
+		return nil
+	}
+	return fmt.Errorf("unsupported image detail %q", o.ImageDetail)
+}
+
+// ValidateReasoningEffort checks that ReasoningEffort is a known value.
+func (o AIClientOptions) ValidateReasoningEffort() error {
+	switch o.ReasoningEffort {
+	case "", ReasoningEffortLow, ReasoningEffortMedium, ReasoningEffortHigh:
+		return nil
+	}
+	return fmt.Errorf("unsupported reasoning effort %q", o.ReasoningEffort)
+}
+
+// ValidateThinkingBudget checks that ThinkingBudget is not negative.
+func (o AIClientOptions) ValidateThinkingBudget() error {
+	if o.ThinkingBudget != nil && *o.ThinkingBudget < 0 {
+		return fmt.Errorf("thinking budget %d cannot be negative", *o.ThinkingBudget)
+	}
+	return nil
+}
+
+// ValidateLogitBias checks that every LogitBias key is a token ID and every
+// bias is within [-MaxLogitBias, MaxLogitBias].
+func (o AIClientOptions) ValidateLogitBias() error {
+	for token, bias := range o.LogitBias {
+		if id, err := strconv.ParseUint(token, 10, 32); err != nil || strconv.FormatUint(id, 10) != token {
+			return fmt.Errorf("logit bias key %q is not a token ID", token)
+		}
+		if bias < -MaxLogitBias || bias > MaxLogitBias {
+			return fmt.Errorf("logit bias %d for token %s is out of range [%d, %d]", bias, token, -MaxLogitBias, MaxLogitBias)
+		}
+	}
+	return nil
+}
+
+// ValidateLogProbs checks that TopLogProbs is within [0, MaxTopLogProbs]
+// and only set together with LogProbs.
+func (o AIClientOptions) ValidateLogProbs() error {
+	if o.TopLogProbs < 0 || o.TopLogProbs > MaxTopLogProbs {
+		return fmt.Errorf("top logprobs %d is out of range [0, %d]", o.TopLogProbs, MaxTopLogProbs)
+	}
+	if o.TopLogProbs > 0 && !o.LogProbs {
+		return fmt.Errorf("top logprobs requires LogProbs")
+	}
+	return nil
+}
+
+// ValidateImageCount checks the number of images in message against
+// MaxImages, or against providerMax when MaxImages is 0. A providerMax of 0
+// means provider has no limit.
+func (o AIClientOptions) ValidateImageCount(provider string, message AIChatMessage, providerMax int) error {
+	limit := providerMax
+	if o.MaxImages != 0 {
+		limit = o.MaxImages
+	}
+	if limit <= 0 {
+		return nil
+	}
+	if count := message.ImageCount(); count > limit {
+		return &AIError{
+			Kind:     ErrorKindInvalidRequest,
+			Provider: provider,
+			Err:      fmt.Errorf("%w: the message has %d images, more than the limit of %d for %s; set AIClientOptions.MaxImages to change it", ErrTooManyImages, count, limit, provider),
+		}
+	}
+	return nil
+}
+
+// UnsupportedLogitBias returns nil when LogitBias can be ignored by
+// provider, and an error matching ErrLogitBiasUnsupported when
+// StrictLogitBias requires it to be honored.
+func (o AIClientOptions) UnsupportedLogitBias(provider string) error {
+	if len(o.LogitBias) == 0 || !o.StrictLogitBias {
+		return nil
+	}
+	return fmt.Errorf("%w by %s", ErrLogitBiasUnsupported, provider)
+}
+
+// UnsupportedReasoning returns nil when ReasoningEffort can be ignored for
+// model, and an error matching ErrReasoningUnsupported when
+// StrictReasoning requires it to be honored.
+func (o AIClientOptions) UnsupportedReasoning(provider string, model string) error {
+	if o.ReasoningEffort == "" || !o.StrictReasoning {
+		return nil
+	}
+	return fmt.Errorf("%w by %s model %q", ErrReasoningUnsupported, provider, model)
+}
+
+// StreamChunk is a single incremental piece of a streamed response.
+// The final chunk on a stream has Done set; if the stream ended because
+// of a provider error or context cancellation, Err is set as well.
+type StreamChunk struct {
+	Text string
+	Done bool
+	Err  error
+}
+
+// Usage reports the token consumption of a single generation call.
+type Usage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+	TotalTokens      int64
+	// CachedPromptTokens is the part of PromptTokens the provider served
+	// from its prompt cache and bills at a reduced rate: OpenAI's automatic
+	// prompt caching and Gemini cached contents. It is 0 when the provider
+	// does not report it.
+	CachedPromptTokens int64
+}
+
+// Tool describes a function the model may call.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON Schema of the function arguments.
+	Parameters json.RawMessage
+}
+
+// ToolCall is a function call requested by the model. Arguments holds the
+// call arguments as a JSON object.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments json.RawMessage
+}
+
+// ResponsePartKind identifies the content of a ResponsePart.
+type ResponsePartKind string
+
+const (
+	ResponsePartText       ResponsePartKind = "text"
+	ResponsePartToolCall   ResponsePartKind = "tool_call"
+	ResponsePartInlineData ResponsePartKind = "inline_data"
+	// ResponsePartThought is a summary of the model's thinking, returned
+	// with AIClientOptions.IncludeThoughts.
+	ResponsePartThought ResponsePartKind = "thought"
+	// ResponsePartOther covers parts such as executable code or file
+	// references, which are not mapped.
+	ResponsePartOther ResponsePartKind = "other"
+)
+
+// ResponsePart is one part of a response, in the order the provider
+// returned it.
+type ResponsePart struct {
+	Kind ResponsePartKind
+	// Text is set for ResponsePartText and ResponsePartThought.
+	Text string
+	// ToolCall is set for ResponsePartToolCall.
+	ToolCall *ToolCall
+	// Data and MimeType are set for ResponsePartInlineData.
+	Data     []byte
+	MimeType string
+}
+
+// FinishReason is the normalized reason a provider stopped generating.
+type FinishReason string
+
+const (
+	// FinishReasonStop means the model finished naturally or hit a stop sequence.
+	FinishReasonStop FinishReason = "stop"
+	// FinishReasonLength means the output was truncated by MaxTokens.
+	FinishReasonLength FinishReason = "length"
+	// FinishReasonContentFilter means the output was blocked by safety filtering.
+	FinishReasonContentFilter FinishReason = "content_filter"
+	// FinishReasonToolCalls means the model stopped to call tools.
+	FinishReasonToolCalls FinishReason = "tool_calls"
+	// FinishReasonOther covers any other provider-specific reason.
+	FinishReasonOther FinishReason = "other"
+)
+
+// GenerateResult is the full result of a generation call: the response
+// text, any tool calls requested by the model, and the token usage.
+type GenerateResult struct {
+	Text      string
+	ToolCalls []ToolCall
+	Usage     Usage
+	// Candidates holds the text of every candidate when more than one was
+	// requested with AIClientOptions.N. Text is the first of them.
+	Candidates []string
+	// FinishReason is why the first candidate stopped. It is empty when the
+	// provider did not report one.
+	FinishReason FinishReason
+	// Thoughts holds the model's thought summaries when
+	// AIClientOptions.IncludeThoughts is set. Text never includes them.
+	Thoughts string
+	// Parts lists every part of the first candidate in order, including
+	// inline data that Text and ToolCalls leave out. Only Gemini sets it.
+	Parts []ResponsePart
+	// Raw is the provider's response body, set only when
+	// AIClientOptions.IncludeRawResponse is. It is kept on errors returned
+	// after a response was received, such as empty responses.
+	Raw json.RawMessage
+	// LogProbs holds the log probability of each token of the first
+	// candidate when AIClientOptions.LogProbs is set.
+	LogProbs []TokenLogProb
+	// AvgLogProb is the mean of LogProbs, a quick confidence proxy: the
+	// closer to 0, the more certain the model was. It is 0 without
+	// LogProbs.
+	AvgLogProb float64
+}
+
+// TokenLogProb is the log probability of a generated token.
+type TokenLogProb struct {
+	Token   string
+	LogProb float64
+	// TopLogProbs lists the most likely tokens at this position, most
+	// likely first, when AIClientOptions.TopLogProbs is set.
+	TopLogProbs []TokenLogProb
+}
+
+// AverageLogProb returns the mean log probability of tokens, or 0 when
+// there are none.
+func AverageLogProb(tokens []TokenLogProb) float64 {
+	if len(tokens) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, token := range tokens {
+		sum += token.LogProb
+	}
+	return sum / float64(len(tokens))
+}
+
+// Texts returns every candidate text, or just Text for a single completion.
+func (r GenerateResult) Texts() []string {
+	if len(r.Candidates) > 0 {
+		return r.Candidates
+	}
+	return []string{r.Text}
 }
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type ExtractTagsResult struct {
 	CollaborationTypes []int    `json:"collaboration_types,omitempty"`
 	Departments        []string `json:"departments,omitempty"`
@@ -7,17 +12,53 @@ type ExtractTagsResult struct {
 	WorkLocations      []string `json:"work_locations,omitempty"`
 }
 
+// PolishResult is a polished article together with the edits that produced
+// it.
+type PolishResult struct {
+	Text    string         `json:"polished"`
+	Changes []PolishChange `json:"changes"`
+	// Structured reports whether the model returned structured edits. When
+	// it did not, Text holds its plain response and Changes is empty.
+	Structured bool `json:"-"`
+	// Truncated reports whether the draft was cut to fit the context window.
+	Truncated bool `json:"-"`
+}
+
+// PolishChange is one edit made while polishing: Original is the draft
+// text that was replaced, or empty for an insertion, and Replacement is the
+// new text, or empty for a deletion.
+type PolishChange struct {
+	Original    string `json:"original"`
+	Replacement string `json:"replacement"`
+	Reason      string `json:"reason"`
+}
+
+// PolishResultSchema is the JSON Schema of the structured edits requested by
+// PolishWithDiff.
+var PolishResultSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "polished": {"type": "string"},
+    "changes": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "original": {"type": "string"},
+          "replacement": {"type": "string"},
+          "reason": {"type": "string"}
+        },
+        "required": ["original", "replacement", "reason"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["polished", "changes"],
+  "additionalProperties": false
+}`)
+
 func GetExtractTagsSystemPrompt(professionType PlatformType) string {
-	switch professionType {
-	case PlatformTypeApen:
-		return apenExtractTagsPrompt
-	case PlatformTypeNurse:
-		return otherExtractTagsPrompt
-	case PlatformTypePhar:
-		return otherExtractTagsPrompt
-	default:
-		return apenExtractTagsPrompt
-	}
+	return renderProfessionPrompt(PromptKeyExtractTags, professionType)
 }
 
 func GetPolishArticleSystemPrompt(professionType PlatformType) string {
@@ -33,6 +74,34 @@ func GetPolishArticleSystemPrompt(professionType PlatformType) string {
 	}
 }
 
+// GetPolishWithDiffSystemPrompt returns the polish prompt for
+// professionType extended to ask for the edits as JSON.
+func GetPolishWithDiffSystemPrompt(professionType PlatformType) string {
+	return GetPolishArticleSystemPrompt(professionType) + polishDiffPrompt
+}
+
+func GetTranslateSystemPrompt(professionType PlatformType, targetLang string) string {
+	audience := "醫師"
+	switch professionType {
+	case PlatformTypeNurse:
+		audience = "護理師"
+	case PlatformTypePhar:
+		audience = "藥師"
+	}
+	return fmt.Sprintf(translateArticlePrompt, audience, targetLang)
+}
+
+func GetGenerateTitleSystemPrompt(professionType PlatformType, maxChars int) string {
+	audience := "醫師"
+	switch professionType {
+	case PlatformTypeNurse:
+		audience = "護理師"
+	case PlatformTypePhar:
+		audience = "藥師"
+	}
+	return fmt.Sprintf(generateTitlePrompt, audience, maxChars)
+}
+
 const apenPolishArticlePrompt = `
 你是一個專業的醫療社群徵才平台的 AI 寫作助手。
 你的「人設」是：一位資深、專業、且有溫度的醫療 HR 夥伴。
@@ -85,6 +154,22 @@ const otherPolishArticlePrompt = `
     * **僅回傳結果：** 直接輸出潤飾後的完整文章內容即可，不要有任何開頭或結尾的附加說明。
 `
 
+const polishDiffPrompt = `
+4.  **修改紀錄 (Change Log)：**
+    * 本項優先於第 3 點的輸出限制：請改以 JSON 格式回傳，不包含任何 Markdown 語法或前後的說明文字。
+    * "polished"：潤飾排版後的完整文章，內容要求與上述相同。
+    * "changes"：依文章順序列出每一處修改，每一項包含：
+      - "original"：草稿中被修改的原文片段，需逐字引用；新增的內容請填空字串。
+      - "replacement"：修改後的文字；刪除的內容請填空字串。
+      - "reason"：以一句話說明修改原因，例如「改為候選人視角」、「移除站外聯絡方式」。
+{
+  "polished": "潤飾後的完整文章",
+  "changes": [
+    {"original": "負責病房照護", "replacement": "你將在病房守護每一位病人", "reason": "改為候選人視角"}
+  ]
+}
+`
+
 const apenExtractTagsPrompt = `
 # Role
 你是一位精通台灣醫療體系與徵才市場的「結構化資料萃取專家」。你的任務是從醫療徵才文本中，精準提取 4 類核心標籤：工作類型、需求科別、需求職級、職缺地點。
@@ -265,3 +350,34 @@ const otherExtractTagsPrompt = `
   "work_locations": ["職缺地點陣列"]
 }
 `
+
+const translateArticlePrompt = `
+你是一位專精醫療領域的專業翻譯，服務對象是%[1]s社群平台的使用者。
+你的任務是將用戶提供的文章完整翻譯為 BCP-47 語言代碼「%[2]s」所代表的語言。
+
+1.  **醫療術語 (Terminology)：**
+    * 醫學名詞、科別、藥品名稱、檢查項目與職稱請使用目標語言中%[1]s慣用的標準譯名。
+    * 沒有通用譯名的專有名詞、縮寫（例如：PGY、ICU、ENT）請保留原文，不要自行翻譯或展開。
+
+2.  **格式保留 (Formatting)：**
+    * 完整保留原文的段落、換行、點列符號、Emoji 與 Markdown 語法（例如：**粗體**、## 標題、[連結](網址)），只翻譯文字內容。
+    * 網址、數字、金額與日期格式請維持原樣。
+
+3.  **輸出限制 (Output Constraints)：**
+    * 不得增刪或改寫原文的資訊，也不要潤飾內容。
+    * 嚴禁回傳任何「以下是翻譯」等說明文字，直接輸出翻譯後的完整文章即可。
+`
+
+const generateTitlePrompt = `
+你是一個專業的醫療社群徵才平台的 AI 編輯，服務對象是%[1]s社群平台的使用者。
+你的任務是根據用戶提供的文章內容，撰寫一個吸引人的文章標題。
+
+1.  **內容要求 (Content)：**
+    * 標題需點出文章最重要的資訊，例如機構、職缺、科別或地點。
+    * 使用與文章相同的語言撰寫。
+    * 不得加入文章中沒有的資訊。
+
+2.  **輸出限制 (Output Constraints)：**
+    * 標題長度「嚴格」不得超過 %[2]d 個字元。
+    * 只回傳「一個」標題，不要加上引號、編號、Emoji、Markdown 語法或任何說明文字。
+`
@@ -0,0 +1,15 @@
+package models
+
+// BatchRequest is a single generation in a batch.
+type BatchRequest struct {
+	Message AIChatMessage
+	Options AIClientOptions
+}
+
+// BatchResult is the outcome of the BatchRequest at Index.
+type BatchResult struct {
+	Index int
+	Text  string
+	Usage Usage
+	Err   error
+}
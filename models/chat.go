@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ChatTurn is one message of a conversation passed to GenerateChat. Images
+// are only supported in user turns.
+type ChatTurn struct {
+	Role      Role
+	Text      string
+	ImageUrls []string
+	Images    []ImageData
+}
+
+// NewChatMessage maps an ordered conversation onto an AIChatMessage. System
+// turns are joined into SystemPrompt, the last user turn becomes the
+// current message and the turns before it become History. A trailing
+// assistant turn becomes AssistantPrefill, which only some providers
+// support.
+func NewChatMessage(turns []ChatTurn) (AIChatMessage, error) {
+	var message AIChatMessage
+	var systemPrompts []string
+	var conversation []ChatTurn
+	for i, turn := range turns {
+		switch turn.Role {
+		case RoleSystem:
+			if len(turn.ImageUrls) > 0 || len(turn.Images) > 0 {
+				return AIChatMessage{}, fmt.Errorf("turn %d: images are only supported in user turns", i)
+			}
+			systemPrompts = append(systemPrompts, turn.Text)
+		case RoleUser, RoleAssistant:
+			conversation = append(conversation, turn)
+		default:
+			return AIChatMessage{}, fmt.Errorf("turn %d: unsupported turn role %q", i, turn.Role)
+		}
+	}
+	message.SystemPrompt = strings.Join(systemPrompts, "\n\n")
+
+	if n := len(conversation); n > 0 && conversation[n-1].Role == RoleAssistant {
+		if len(conversation[n-1].ImageUrls) > 0 || len(conversation[n-1].Images) > 0 {
+			return AIChatMessage{}, errors.New("images are only supported in user turns")
+		}
+		message.AssistantPrefill = conversation[n-1].Text
+		conversation = conversation[:n-1]
+	}
+
+	n := len(conversation)
+	if n == 0 || conversation[n-1].Role != RoleUser {
+		return AIChatMessage{}, errors.New("conversation must contain a user turn after the last assistant turn")
+	}
+
+	current := conversation[n-1]
+	message.Text = current.Text
+	message.ImageUrls = current.ImageUrls
+	message.Images = current.Images
+
+	for _, turn := range conversation[:n-1] {
+		message.History = append(message.History, Turn(turn))
+	}
+
+	if err := message.ValidateHistory(); err != nil {
+		return AIChatMessage{}, err
+	}
+	return message, nil
+}
@@ -0,0 +1,52 @@
+package models
+
+import "sync"
+
+// DefaultContextWindow is the context window assumed for models missing
+// from the context window table.
+const DefaultContextWindow = 128_000
+
+var (
+	contextWindowMu sync.RWMutex
+	// contextWindows lists input plus output tokens per model. Dated or
+	// suffixed model names match the longest listed prefix.
+	contextWindows = map[string]int{
+		// OpenAI
+		"gpt-4o":  128_000,
+		"gpt-4.1": 1_047_576,
+		"gpt-5":   400_000,
+		"o3":      200_000,
+		"o4-mini": 200_000,
+
+		// Gemini
+		"gemini-2.5":       1_048_576,
+		"gemini-2.0-flash": 1_048_576,
+
+		// Anthropic, directly and on Bedrock
+		"claude-":             200_000,
+		"anthropic.claude-":   200_000,
+		"us.anthropic.claude": 200_000,
+
+		// Ollama
+		"llama3.2-vision": 128_000,
+		"llava":           4_096,
+	}
+)
+
+// ContextWindow returns the context window of model in tokens, or
+// DefaultContextWindow when the model is unknown.
+func ContextWindow(model string) int {
+	contextWindowMu.RLock()
+	defer contextWindowMu.RUnlock()
+	if tokens, ok := lookupModel(contextWindows, model); ok {
+		return tokens
+	}
+	return DefaultContextWindow
+}
+
+// SetContextWindow overrides or adds the context window of model.
+func SetContextWindow(model string, tokens int) {
+	contextWindowMu.Lock()
+	defer contextWindowMu.Unlock()
+	contextWindows[model] = tokens
+}
@@ -0,0 +1,19 @@
+package models
+
+import "context"
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a copy of ctx carrying id, e.g. the ID of the
+// upstream request an OCR scan belongs to. The stores and the logging
+// client add it to their log lines as correlation_id, next to the
+// request_id the logging package derives from the trace in ctx.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the ID set with WithCorrelationID, or "".
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// TokenPrice is the price in USD per 1,000 tokens.
+type TokenPrice struct {
+	InputPer1K  float64
+	OutputPer1K float64
+	// CachedInputPer1K prices Usage.CachedPromptTokens. When 0, cached
+	// tokens are priced at InputPer1K.
+	CachedInputPer1K float64
+}
+
+// CostModel maps model names to their token prices. Dated or suffixed model
+// names (e.g. "gpt-4o-2024-08-06") match the longest listed prefix.
+type CostModel map[string]TokenPrice
+
+// EstimateCost returns the estimated USD cost of usage for model.
+func (m CostModel) EstimateCost(model string, usage Usage) (float64, error) {
+	price, ok := m.lookup(model)
+	if !ok {
+		return 0, fmt.Errorf("no price configured for model %q", model)
+	}
+
+	cachedPer1K := price.CachedInputPer1K
+	if cachedPer1K == 0 {
+		cachedPer1K = price.InputPer1K
+	}
+	cached := min(usage.CachedPromptTokens, usage.PromptTokens)
+
+	return float64(usage.PromptTokens-cached)/1000*price.InputPer1K +
+		float64(cached)/1000*cachedPer1K +
+		float64(usage.CompletionTokens)/1000*price.OutputPer1K, nil
+}
+
+func (m CostModel) lookup(model string) (TokenPrice, bool) {
+	return lookupModel(m, model)
+}
+
+// lookupModel returns the entry for model, or for the longest listed
+// prefix of model.
+func lookupModel[V any](m map[string]V, model string) (V, bool) {
+	if value, ok := m[model]; ok {
+		return value, true
+	}
+
+	var best string
+	for name := range m {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		var zero V
+		return zero, false
+	}
+	return m[best], true
+}
+
+var (
+	defaultCostMu    sync.RWMutex
+	defaultCostModel = CostModel{
+		// OpenAI
+		"gpt-4o":       {InputPer1K: 0.0025, OutputPer1K: 0.01, CachedInputPer1K: 0.00125},
+		"gpt-4o-mini":  {InputPer1K: 0.00015, OutputPer1K: 0.0006, CachedInputPer1K: 0.000075},
+		"gpt-4.1":      {InputPer1K: 0.002, OutputPer1K: 0.008, CachedInputPer1K: 0.0005},
+		"gpt-4.1-mini": {InputPer1K: 0.0004, OutputPer1K: 0.0016, CachedInputPer1K: 0.0001},
+		"gpt-4.1-nano": {InputPer1K: 0.0001, OutputPer1K: 0.0004, CachedInputPer1K: 0.000025},
+		"gpt-5":        {InputPer1K: 0.00125, OutputPer1K: 0.01, CachedInputPer1K: 0.000125},
+		"gpt-5-mini":   {InputPer1K: 0.00025, OutputPer1K: 0.002, CachedInputPer1K: 0.000025},
+		"gpt-5-nano":   {InputPer1K: 0.00005, OutputPer1K: 0.0004, CachedInputPer1K: 0.000005},
+		"o3":           {InputPer1K: 0.002, OutputPer1K: 0.008, CachedInputPer1K: 0.0005},
+		"o4-mini":      {InputPer1K: 0.0011, OutputPer1K: 0.0044, CachedInputPer1K: 0.000275},
+
+		// Gemini
+		"gemini-2.5-pro":        {InputPer1K: 0.00125, OutputPer1K: 0.01},
+		"gemini-2.5-flash":      {InputPer1K: 0.0003, OutputPer1K: 0.0025},
+		"gemini-2.5-flash-lite": {InputPer1K: 0.0001, OutputPer1K: 0.0004},
+		"gemini-2.0-flash":      {InputPer1K: 0.0001, OutputPer1K: 0.0004},
+		"gemini-2.0-flash-lite": {InputPer1K: 0.000075, OutputPer1K: 0.0003},
+	}
+)
+
+// SetModelPrice overrides or adds the default price for model.
+func SetModelPrice(model string, price TokenPrice) {
+	defaultCostMu.Lock()
+	defer defaultCostMu.Unlock()
+	defaultCostModel[model] = price
+}
+
+// EstimateCost returns the estimated USD cost of usage for model using the
+// default price table.
+func EstimateCost(model string, usage Usage) (float64, error) {
+	defaultCostMu.RLock()
+	defer defaultCostMu.RUnlock()
+	return defaultCostModel.EstimateCost(model, usage)
+}
@@ -0,0 +1,100 @@
+package models
+
+import "encoding/json"
+
+// EntityType is the kind of an entity extracted from article content.
+type EntityType string
+
+const (
+	EntityTypeDrug      EntityType = "drug"
+	EntityTypeCondition EntityType = "condition"
+	EntityTypeDosage    EntityType = "dosage"
+)
+
+// Entity is a term found in article content for indexing. Text is quoted
+// from the content as written; NormalizedValue is its standard form, such
+// as the generic drug name for a brand name, or nil when the model gives
+// none.
+type Entity struct {
+	Type            EntityType `json:"type"`
+	Text            string     `json:"text"`
+	NormalizedValue *string    `json:"normalized_value"`
+}
+
+// ExtractEntitiesSchema is the JSON Schema of the response requested by
+// ExtractEntities. The entities are wrapped in an object because structured
+// output must be an object.
+var ExtractEntitiesSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "entities": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "type": {"type": "string", "enum": ["drug", "condition", "dosage"]},
+          "text": {"type": "string"},
+          "normalized_value": {"type": ["string", "null"]}
+        },
+        "required": ["type", "text", "normalized_value"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["entities"],
+  "additionalProperties": false
+}`)
+
+func GetExtractEntitiesSystemPrompt(professionType PlatformType) string {
+	return renderProfessionPrompt(PromptKeyExtractEntities, professionType)
+}
+
+const apenExtractEntitiesPrompt = `
+# Role
+你是一位熟悉台灣醫療體系的「醫療實體萃取專家」，服務對象是醫師社群平台。你的任務是從用戶提供的文章中，找出可供搜尋索引的醫療實體。
+
+# Entity Types
+• drug：藥品，包含學名、商品名與藥物類別（例如：Metformin、保栓通、PPI）。
+• condition：疾病、症狀與診斷（例如：第二型糖尿病、GERD、胸痛）。
+• dosage：劑量、頻次與給藥途徑（例如：500mg BID、每日一次口服、IV 1g q8h）。
+
+# Rules
+• "text"：逐字引用文章中的原文，不要改寫。同一實體重複出現時只列一次。
+• "normalized_value"：標準化後的名稱。藥品請使用英文學名（例如：保栓通 → Clopidogrel），疾病請使用醫師慣用的標準英文或中文病名，劑量請統一為「劑量 單位 頻次」格式（例如：500 mg BID）。無法確定時請填 null，不要猜測。
+• 醫院名稱、科別、職稱、地點與薪資等徵才資訊不是醫療實體，請勿列出。
+• 若文章中沒有任何醫療實體，請回傳空陣列。
+
+# Output Format (JSON)
+請嚴格只輸出 JSON 格式，不包含任何 Markdown 語法或前後的廢話。
+{
+  "entities": [
+    {"type": "drug", "text": "保栓通", "normalized_value": "Clopidogrel"},
+    {"type": "dosage", "text": "每天一顆 75mg", "normalized_value": "75 mg QD"}
+  ]
+}
+`
+
+const otherExtractEntitiesPrompt = `
+# Role
+你是一位熟悉台灣醫療體系的「醫療實體萃取專家」，服務對象是{{if eq .ProfessionType "phar"}}藥師{{else}}護理師{{end}}社群平台。你的任務是從用戶提供的文章中，找出可供搜尋索引的醫療實體。
+
+# Entity Types
+• drug：藥品，包含學名、商品名與藥物類別（例如：Metformin、普拿疼、抗凝血劑）。
+• condition：疾病、症狀與護理問題（例如：高血壓、壓瘡、跌倒風險）。
+• dosage：劑量、頻次與給藥途徑（例如：500mg BID、每日一次口服、IV drip）。
+
+# Rules
+• "text"：逐字引用文章中的原文，不要改寫。同一實體重複出現時只列一次。
+• "normalized_value"：標準化後的名稱。藥品請使用英文學名（例如：普拿疼 → Acetaminophen），疾病請使用標準病名，劑量請統一為「劑量 單位 頻次」格式（例如：500 mg BID）。無法確定時請填 null，不要猜測。
+• 醫院名稱、單位、職稱、地點與薪資等徵才資訊不是醫療實體，請勿列出。
+• 若文章中沒有任何醫療實體，請回傳空陣列。
+
+# Output Format (JSON)
+請嚴格只輸出 JSON 格式，不包含任何 Markdown 語法或前後的廢話。
+{
+  "entities": [
+    {"type": "drug", "text": "普拿疼", "normalized_value": "Acetaminophen"},
+    {"type": "dosage", "text": "一天三次", "normalized_value": "TID"}
+  ]
+}
+`
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrRequestTimeout is returned, wrapped, when AIClientOptions.RequestTimeout
+// expires before the provider responds. A caller-cancelled context does not
+// match it.
+var ErrRequestTimeout = errors.New("AI request timed out")
+
+// ErrClientClosed is returned by calls made after an AIClient was closed.
+var ErrClientClosed = errors.New("AI client is closed")
+
+// ErrorKind classifies why a provider call failed.
+type ErrorKind string
+
+const (
+	ErrorKindUnknown        ErrorKind = "unknown"
+	ErrorKindRateLimited    ErrorKind = "rate_limited"
+	ErrorKindInvalidRequest ErrorKind = "invalid_request"
+	ErrorKindAuth           ErrorKind = "auth"
+	ErrorKindServerError    ErrorKind = "server_error"
+	ErrorKindTimeout        ErrorKind = "timeout"
+	ErrorKindEmptyResponse  ErrorKind = "empty_response"
+	// ErrorKindContentFiltered means the provider's safety filtering blocked
+	// the prompt or the response. Retrying the same request will not help.
+	ErrorKindContentFiltered ErrorKind = "content_filtered"
+	// ErrorKindInvalidJSON means a JSON response could not be parsed, even
+	// after repairing common defects.
+	ErrorKindInvalidJSON ErrorKind = "invalid_json"
+)
+
+// AIError is returned by the AI clients when a provider call fails. The
+// original provider error is kept in Err. StatusCode is zero when the
+// failure did not come with an HTTP status.
+type AIError struct {
+	Kind       ErrorKind
+	Provider   string
+	StatusCode int
+	// Reason is the provider's explanation when it gives one, such as the
+	// block reason of a content-filtered response.
+	Reason string
+	// RawResponse holds the model output that failed to parse for
+	// ErrorKindInvalidJSON.
+	RawResponse string
+	Err         error
+}
+
+// NewAIError wraps a provider error, deriving Kind from the HTTP status.
+func NewAIError(provider string, statusCode int, err error) *AIError {
+	return &AIError{
+		Kind:       KindFromStatus(statusCode),
+		Provider:   provider,
+		StatusCode: statusCode,
+		Err:        err,
+	}
+}
+
+// NewContentFilteredError reports that provider blocked a prompt or
+// response for reason.
+func NewContentFilteredError(provider string, reason string) *AIError {
+	return &AIError{
+		Kind:     ErrorKindContentFiltered,
+		Provider: provider,
+		Reason:   reason,
+		Err:      fmt.Errorf("%s blocked the request by content filtering: %s", provider, reason),
+	}
+}
+
+// NewInvalidJSONError reports that raw could not be parsed as JSON.
+func NewInvalidJSONError(raw string, err error) *AIError {
+	return &AIError{
+		Kind:        ErrorKindInvalidJSON,
+		RawResponse: raw,
+		Err:         fmt.Errorf("invalid JSON in model response: %w", err),
+	}
+}
+
+func (e *AIError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AIError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches another *AIError of the same Kind, so callers can write
+// errors.Is(err, &models.AIError{Kind: models.ErrorKindRateLimited}).
+func (e *AIError) Is(target error) bool {
+	t, ok := target.(*AIError)
+	return ok && t.Kind == e.Kind
+}
+
+// KindOf returns the Kind of the first *AIError in err's chain, or
+// ErrorKindUnknown when there is none.
+func KindOf(err error) ErrorKind {
+	var aiErr *AIError
+	if errors.As(err, &aiErr) {
+		return aiErr.Kind
+	}
+	return ErrorKindUnknown
+}
+
+// KindFromStatus maps an HTTP status code onto an ErrorKind.
+func KindFromStatus(statusCode int) ErrorKind {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return ErrorKindRateLimited
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return ErrorKindAuth
+	case statusCode == http.StatusRequestTimeout, statusCode == http.StatusGatewayTimeout:
+		return ErrorKindTimeout
+	case statusCode >= 500:
+		return ErrorKindServerError
+	case statusCode >= 400:
+		return ErrorKindInvalidRequest
+	}
+	return ErrorKindUnknown
+}
@@ -0,0 +1,19 @@
+package models
+
+import "errors"
+
+// ErrContentFlagged is returned, wrapped, when content fails moderation.
+var ErrContentFlagged = errors.New("content flagged by moderation")
+
+// ModerationCategories are the categories a prompt-based moderator scores.
+// They use the names of OpenAI's moderation endpoint.
+var ModerationCategories = []string{"harassment", "hate", "self-harm", "sexual", "violence", "illicit"}
+
+// ModerationResult is the outcome of a moderation check.
+type ModerationResult struct {
+	Flagged bool
+	// Categories lists the flagged categories.
+	Categories []string
+	// Scores maps each category to a confidence between 0 and 1.
+	Scores map[string]float64
+}
@@ -1,18 +1,30 @@
 package models
 
 import (
+	"fmt"
 	"time"
 )
 
 type OCRRawInfo struct {
-	IdentifyURL        *string `json:"identify_url,omitempty"`
-	Name               *string `json:"name"`
-	Birthday           *string `json:"birthday"`
-	Position           *string `json:"position,omitempty"`
-	Department         *string `json:"department,omitempty"`
-	Facility           *string `json:"facility,omitempty"`
-	ValidDate          *string `json:"valid_date,omitempty"`
-	SpecialtyValidDate *string `json:"specialty_valid_date,omitempty"`
+	IdentifyURL        *string  `json:"identify_url,omitempty"`
+	IdentifyURLs       []string `json:"identify_urls,omitempty"` // set by multi-image scans, or with store.Config.IncludeIdentifyURLs
+	Name               *string  `json:"name"`
+	Birthday           *string  `json:"birthday"`
+	Position           *string  `json:"position,omitempty"`
+	Department         *string  `json:"department,omitempty"`
+	Facility           *string  `json:"facility,omitempty"`
+	ValidDate          *string  `json:"valid_date,omitempty"`
+	SpecialtyValidDate *string  `json:"specialty_valid_date,omitempty"`
+	// Confidence is the model's overall confidence in the result, from 0 to
+	// 1. It is 0 when the model did not report one.
+	Confidence float64 `json:"confidence"`
+	// DetectedLanguage is the main language of the document as a BCP-47
+	// code, such as "zh-TW" or "en". It is empty when the model did not
+	// report one.
+	DetectedLanguage string `json:"detected_language,omitempty"`
+	// ScannedAt is when the scan completed. It is only set with
+	// store.Config.IncludeScannedAt.
+	ScannedAt *time.Time `json:"scanned_at,omitempty"`
 }
 
 type OCRInfo struct {
@@ -51,16 +63,33 @@ const (
 	PlatformTypePhar  PlatformType = "phar"
 )
 
-func GetInfoPrompt(professionType PlatformType) string {
-	switch professionType {
-	case PlatformTypeApen:
-		return apenInfoPrompt
-	case PlatformTypeNurse:
-		return nurseInfoPrompt
-	case PlatformTypePhar:
-		return pharInfoPrompt
+// OutputLanguage selects the language of the text fields of an OCR scan.
+// Besides the constants below it may be a BCP-47 code, such as "en", to
+// translate the values into that language.
+type OutputLanguage string
+
+const (
+	// OutputLanguageOriginal keeps the values as written on the document.
+	// It is the default.
+	OutputLanguageOriginal OutputLanguage = ""
+	// OutputLanguageRomanized romanizes the values, e.g. names as on a
+	// Taiwanese passport.
+	OutputLanguageRomanized OutputLanguage = "romanized"
+)
+
+// GetInfoPrompt returns the info prompt for professionType. Unless
+// outputLanguage is OutputLanguageOriginal, it ends with an instruction to
+// write the text fields in that language; dates, position, confidence and
+// detected_language keep their format.
+func GetInfoPrompt(professionType PlatformType, outputLanguage OutputLanguage) string {
+	prompt := renderProfessionPrompt(PromptKeyOCRInfo, professionType)
+	switch outputLanguage {
+	case OutputLanguageOriginal:
+		return prompt
+	case OutputLanguageRomanized:
+		return prompt + romanizedOutputPrompt
 	default:
-		return apenInfoPrompt // default to apen
+		return prompt + fmt.Sprintf(translatedOutputPrompt, outputLanguage)
 	}
 }
 
@@ -95,6 +124,10 @@ const apenInfoPrompt = `
 7. **specialty_valid_date（專科證書生效日期）**: 格式為 YYYY-MM-DD
    - 僅當圖片為「專科證書」時才需辨識
    - 注意：這是「生效日期」或「頒發日期」，不是「有效日期」
+8. **confidence（信心分數）**: 0 到 1 之間的數字，表示整體辨識結果的可信度
+   - 圖片模糊、欄位難以辨識或需要推測時請給較低的分數
+9. **detected_language（文件語言）**: 文件主要使用的語言，以 BCP-47 代碼表示
+   - 例如繁體中文為 "zh-TW"、英文為 "en"
 
 **輸出格式：**
 請以以下 JSON 格式輸出（如果找不到對應資料或無法辨識，請將該欄位的值設為 null）：
@@ -106,7 +139,9 @@ const apenInfoPrompt = `
   "department": "科別",
   "facility": "執業場所/任職場所",
   "valid_date": "YYYY-MM-DD",
-  "specialty_valid_date": "YYYY-MM-DD"
+  "specialty_valid_date": "YYYY-MM-DD",
+  "confidence": 0.95,
+  "detected_language": "zh-TW"
 }
 	`
 
@@ -123,6 +158,10 @@ const nurseInfoPrompt = `
 5. **valid_date（護理師證書生效日期）**: 格式為 YYYY-MM-DD
    - 僅當圖片為「護理師證書」時才需辨識
    - 執業執照不需要填寫此欄位
+6. **confidence（信心分數）**: 0 到 1 之間的數字，表示整體辨識結果的可信度
+   - 圖片模糊、欄位難以辨識或需要推測時請給較低的分數
+7. **detected_language（文件語言）**: 文件主要使用的語言，以 BCP-47 代碼表示
+   - 例如繁體中文為 "zh-TW"、英文為 "en"
 
 **輸出格式：**
 請以以下 JSON 格式輸出（如果找不到對應資料或無法辨識，請將該欄位的值設為 null）：
@@ -132,7 +171,9 @@ const nurseInfoPrompt = `
   "birthday": "YYYY-MM-DD",
   "department": "科別",
   "facility": "執業場所/任職場所",
-  "valid_date": "YYYY-MM-DD"
+  "valid_date": "YYYY-MM-DD",
+  "confidence": 0.95,
+  "detected_language": "zh-TW"
 }
 	`
 
@@ -148,6 +189,10 @@ const pharInfoPrompt = `
 4. **valid_date（藥師證書生效日期）**: 格式為 YYYY-MM-DD
    - 僅當圖片為「藥師證書」時才需辨識
    - 執業執照不需要填寫此欄位
+5. **confidence（信心分數）**: 0 到 1 之間的數字，表示整體辨識結果的可信度
+   - 圖片模糊、欄位難以辨識或需要推測時請給較低的分數
+6. **detected_language（文件語言）**: 文件主要使用的語言，以 BCP-47 代碼表示
+   - 例如繁體中文為 "zh-TW"、英文為 "en"
 
 **輸出格式：**
 請以以下 JSON 格式輸出（如果找不到對應資料或無法辨識，請將該欄位的值設為 null）：
@@ -156,6 +201,32 @@ const pharInfoPrompt = `
   "name": "中文姓名",
   "birthday": "YYYY-MM-DD",
   "facility": "執業場所/任職場所",
-  "valid_date": "YYYY-MM-DD"
+  "valid_date": "YYYY-MM-DD",
+  "confidence": 0.95,
+  "detected_language": "zh-TW"
 }
 	`
+
+const romanizedOutputPrompt = `
+**輸出語言：**
+請將 name、department 與 facility 等文字欄位以羅馬拼音輸出，不要保留中文：
+- 姓名請使用台灣護照慣用的威妥瑪拼音，全部大寫，姓氏在前並以逗號分隔，名字以連字號連接（例如：「王小明」輸出為 "WANG, HSIAO-MING"）
+- 文件上已有英文姓名或英文名稱時，請直接使用文件上的寫法
+- 日期、position、confidence 與 detected_language 維持上述格式，detected_language 仍為文件本身的語言
+	`
+
+const translatedOutputPrompt = `
+**輸出語言：**
+請將 name、department 與 facility 等文字欄位以 BCP-47 語言代碼「%s」所代表的語言輸出：
+- 科別與執業場所請使用該語言的標準譯名；沒有通用譯名時請音譯
+- 姓名請使用該語言慣用的音譯寫法；文件上已有該語言的寫法時，請直接使用
+- 日期、position、confidence 與 detected_language 維持上述格式，detected_language 仍為文件本身的語言
+	`
+
+// ScanNameResult is the outcome of scanning the badge photo at Link. Err is
+// set instead of Name when that photo could not be scanned.
+type ScanNameResult struct {
+	Link string
+	Name string
+	Err  error
+}
@@ -0,0 +1,58 @@
+package models
+
+import "fmt"
+
+// ocrFieldInstructions holds the focused instruction ScanField uses for
+// each known field: what the field looks like and how to read it.
+var ocrFieldInstructions = map[string]string{
+	"name": `**name（姓名）**: 中文姓名
+   - 請逐字確認，注意形近字（例如：「鈺」與「玉」、「瑋」與「偉」）`,
+	"birthday": `**birthday（生日）**: 格式為 YYYY-MM-DD
+   - 民國年請加 1911 轉換為西元年（例如：民國 80 年為 1991 年）`,
+	"position": `**position（職級）**: 僅限 "PGY"、"Resident" 或 "VS"
+   - 專科證書必定為 VS；如果只標註「醫師」而無具體職級則設為 null`,
+	"department": `**department（科別）**: 中文科別名稱`,
+	"facility":   `**facility（執業場所）**: 任職場所或執業場所的完整名稱`,
+	"valid_date": `**valid_date（證書生效日期）**: 格式為 YYYY-MM-DD
+   - 這是「生效日期」或「頒發日期」，不是「有效日期」
+   - 民國年請加 1911 轉換為西元年`,
+	"specialty_valid_date": `**specialty_valid_date（專科證書生效日期）**: 格式為 YYYY-MM-DD
+   - 這是「生效日期」或「頒發日期」，不是「有效日期」
+   - 民國年請加 1911 轉換為西元年`,
+	"license_number": `**license_number（證書字號）**: 證書或執照上的字號，例如「醫字第 012345 號」中的完整字號
+   - 請逐字元抄寫，保留所有英文字母、數字與前導零，不要補字或省略
+   - 注意易混淆的字元：數字 0 與字母 O、數字 1 與字母 I 或 l、數字 5 與字母 S、數字 8 與字母 B`,
+}
+
+// GetFieldPrompt returns a prompt that asks for a single field of the
+// document, in the JSON form {"value": ...}. Known fields, such as
+// "license_number" or the fields of OCRRawInfo, get a focused instruction;
+// any other field is asked for by name.
+func GetFieldPrompt(field string, professionType PlatformType) string {
+	instruction, ok := ocrFieldInstructions[field]
+	if !ok {
+		instruction = fmt.Sprintf("**%s**: 圖片中對應此欄位的內容", field)
+	}
+
+	audience := "醫師"
+	switch professionType {
+	case PlatformTypeNurse:
+		audience = "護理師"
+	case PlatformTypePhar:
+		audience = "藥師"
+	}
+	return fmt.Sprintf(fieldPrompt, audience, instruction)
+}
+
+const fieldPrompt = `
+請分析這張圖片（可能是%s的識別證、執照、證書或名片），只需要辨識以下「一個」欄位，請專注並仔細確認每一個字元：
+
+%s
+
+**輸出格式：**
+請以以下 JSON 格式輸出（如果找不到對應資料或無法辨識，請將 "value" 的值設為 null）：
+
+{
+  "value": "欄位內容"
+}
+	`
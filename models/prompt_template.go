@@ -0,0 +1,85 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Keys of the built-in prompts. Profession-specific prompts are registered
+// as the prefix followed by the PlatformType, e.g. "ocr_info.nurse".
+const (
+	PromptKeyOCRInfo         = "ocr_info."
+	PromptKeyExtractTags     = "extract_tags."
+	PromptKeyExtractEntities = "extract_entities."
+)
+
+// PromptData is passed to profession-specific prompt templates.
+type PromptData struct {
+	ProfessionType PlatformType
+}
+
+var (
+	promptsMu sync.RWMutex
+	prompts   = map[string]*template.Template{}
+)
+
+func init() {
+	builtin := map[string]string{
+		PromptKeyOCRInfo + string(PlatformTypeApen):          apenInfoPrompt,
+		PromptKeyOCRInfo + string(PlatformTypeNurse):         nurseInfoPrompt,
+		PromptKeyOCRInfo + string(PlatformTypePhar):          pharInfoPrompt,
+		PromptKeyExtractTags + string(PlatformTypeApen):      apenExtractTagsPrompt,
+		PromptKeyExtractTags + string(PlatformTypeNurse):     otherExtractTagsPrompt,
+		PromptKeyExtractTags + string(PlatformTypePhar):      otherExtractTagsPrompt,
+		PromptKeyExtractEntities + string(PlatformTypeApen):  apenExtractEntitiesPrompt,
+		PromptKeyExtractEntities + string(PlatformTypeNurse): otherExtractEntitiesPrompt,
+		PromptKeyExtractEntities + string(PlatformTypePhar):  otherExtractEntitiesPrompt,
+	}
+	for key, tmpl := range builtin {
+		prompts[key] = template.Must(template.New(key).Parse(tmpl))
+	}
+}
+
+// RegisterPrompt adds or replaces the prompt template stored under key.
+// tmpl uses text/template syntax. Call it at startup to override a
+// built-in prompt or to add one for a new profession type.
+func RegisterPrompt(key string, tmpl string) error {
+	t, err := template.New(key).Parse(tmpl)
+	if err != nil {
+		return fmt.Errorf("failed to parse prompt %q: %w", key, err)
+	}
+
+	promptsMu.Lock()
+	defer promptsMu.Unlock()
+	prompts[key] = t
+	return nil
+}
+
+// RenderPrompt executes the prompt template stored under key with data.
+func RenderPrompt(key string, data any) (string, error) {
+	promptsMu.RLock()
+	t, ok := prompts[key]
+	promptsMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("prompt %q is not registered", key)
+	}
+
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %q: %w", key, err)
+	}
+	return sb.String(), nil
+}
+
+// renderProfessionPrompt renders prefix+professionType, falling back to the
+// doctor prompt for profession types without a registered prompt.
+func renderProfessionPrompt(prefix string, professionType PlatformType) string {
+	data := PromptData{ProfessionType: professionType}
+	prompt, err := RenderPrompt(prefix+string(professionType), data)
+	if err != nil {
+		prompt, _ = RenderPrompt(prefix+string(PlatformTypeApen), data)
+	}
+	return prompt
+}
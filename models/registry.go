@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownModel is returned, wrapped in an *AIError of kind
+// ErrorKindInvalidRequest, when AIClientOptions.Model is missing from the
+// provider's model registry.
+var ErrUnknownModel = errors.New("unknown model for provider")
+
+var (
+	modelRegistryMu sync.RWMutex
+	// modelRegistry lists the chat models each provider is known to serve.
+	// A listed name also matches its dated or tagged variants, such as
+	// "gpt-4o-2024-08-06" for "gpt-4o" or "llava:13b" for "llava".
+	// Providers without an entry, such as ollama, are not validated.
+	modelRegistry = map[string][]string{
+		"openai": {
+			"gpt-5", "gpt-5-mini", "gpt-5-nano",
+			"gpt-4.1", "gpt-4.1-mini", "gpt-4.1-nano",
+			"gpt-4o", "gpt-4o-mini", "chatgpt-4o-latest",
+			"gpt-4-turbo", "gpt-4", "gpt-3.5-turbo",
+			"o1", "o1-mini", "o3", "o3-mini", "o4-mini",
+		},
+		"gemini": {
+			"gemini-3-pro",
+			"gemini-2.5-pro", "gemini-2.5-flash", "gemini-2.5-flash-lite",
+			"gemini-2.0-flash", "gemini-2.0-flash-lite",
+			"gemini-1.5-pro", "gemini-1.5-flash",
+		},
+		"anthropic": {
+			"claude-opus-4", "claude-sonnet-4", "claude-haiku-4",
+			"claude-3-7-sonnet", "claude-3-5-sonnet", "claude-3-5-haiku",
+			"claude-3-opus", "claude-3-haiku",
+		},
+		"bedrock": {
+			"anthropic.claude", "us.anthropic.claude", "eu.anthropic.claude",
+			"apac.anthropic.claude", "global.anthropic.claude",
+			"amazon.titan-text-express", "amazon.titan-text-lite",
+			"amazon.titan-text-premier",
+		},
+	}
+)
+
+// RegisterModels adds models to provider's registry, e.g. to allow a newly
+// released model everywhere without setting AllowUnknownModels per call.
+func RegisterModels(provider string, names ...string) {
+	modelRegistryMu.Lock()
+	defer modelRegistryMu.Unlock()
+	modelRegistry[provider] = append(modelRegistry[provider], names...)
+}
+
+// IsKnownModel reports whether model is in provider's registry. Every model
+// is known for a provider without a registry.
+func IsKnownModel(provider string, model string) bool {
+	modelRegistryMu.RLock()
+	defer modelRegistryMu.RUnlock()
+
+	names, ok := modelRegistry[provider]
+	if !ok {
+		return true
+	}
+	for _, name := range names {
+		if model == name {
+			return true
+		}
+		if rest, ok := strings.CutPrefix(model, name); ok && (rest[0] == '-' || rest[0] == ':') {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateModel checks Model against provider's registry before it is sent,
+// so a typo such as "gpt4o" fails fast instead of with a provider 404. An
+// empty Model, which selects the client default, and AllowUnknownModels
+// skip the check.
+func (o AIClientOptions) ValidateModel(provider string) error {
+	if o.Model == "" || o.AllowUnknownModels || IsKnownModel(provider, o.Model) {
+		return nil
+	}
+	return &AIError{
+		Kind:     ErrorKindInvalidRequest,
+		Provider: provider,
+		Err:      fmt.Errorf("%w %s: %q; register it with models.RegisterModels or set AllowUnknownModels", ErrUnknownModel, provider, o.Model),
+	}
+}
@@ -2,14 +2,41 @@ package store
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/util"
+	"github.com/A-pen-app/logging"
+	"golang.org/x/text/language"
 )
 
+// MaxTranslateTokens caps the output budget Translate sizes from its input,
+// keeping a long article within what models can produce in one response.
+// ArticleConfig.MaxToken is used instead when it is higher.
+var MaxTranslateTokens int64 = 8192
+
 type ArticleConfig struct {
 	MaxToken int64
+	// TruncateInput cuts Polish input that would not fit the context window
+	// of ContextModel once the system prompt and MaxToken output tokens are
+	// reserved. Use PolishWithTruncation to learn whether it was cut.
+	TruncateInput bool
+	// ContextModel names the model whose context window TruncateInput
+	// respects (default: the model of the call, see WithModel; without one,
+	// models.DefaultContextWindow tokens).
+	ContextModel string
+	// DefaultOptions applies to every call, e.g. Model, Temperature or
+	// RequestTimeout. MaxToken, the response format each method needs and
+	// a model set with WithModel take precedence over it.
+	DefaultOptions models.AIClientOptions
+	// Moderator, when set, checks content before Polish and PolishWithDiff,
+	// which then refuse flagged content with models.ErrContentFlagged. It
+	// also serves Moderate.
+	Moderator Moderator
 }
 
 type articleStore struct {
@@ -30,7 +57,7 @@ func NewArticleStore(aiClient AIClient, config *ArticleConfig) Article {
 	}
 }
 
-func (s *articleStore) ExtractTags(ctx context.Context, content string, professionType models.PlatformType) (*models.ExtractTagsResult, error) {
+func (s *articleStore) ExtractTags(ctx context.Context, content string, professionType models.PlatformType, callOpts ...CallOption) (*models.ExtractTagsResult, error) {
 	if s.aiClient == nil {
 		return nil, fmt.Errorf("AI client is not initialized")
 	}
@@ -44,33 +71,210 @@ func (s *articleStore) ExtractTags(ctx context.Context, content string, professi
 	}
 
 	opts := models.AIClientOptions{
+		Model:          callModel(callOpts),
 		MaxTokens:      s.cfg.MaxToken,
 		ResponseFormat: models.ResponseFormatJSON,
+	}.WithDefaults(s.cfg.DefaultOptions)
+
+	result, err := GenerateInto[models.ExtractTagsResult](ctx, s.aiClient, message, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	resp, err := s.aiClient.Generate(ctx, message, opts)
+	return &result, nil
+}
+
+// extractEntitiesResponse is the JSON ExtractEntities asks for.
+type extractEntitiesResponse struct {
+	Entities []models.Entity `json:"entities"`
+}
+
+// ExtractEntities finds drugs, conditions and dosages in content for
+// indexing. It returns an empty slice when there are none. Entities with
+// empty text or a type outside the schema are dropped.
+func (s *articleStore) ExtractEntities(ctx context.Context, content string, professionType models.PlatformType, callOpts ...CallOption) ([]models.Entity, error) {
+	if s.aiClient == nil {
+		return nil, fmt.Errorf("AI client is not initialized")
+	}
+
+	message := models.AIChatMessage{
+		SystemPrompt: models.GetExtractEntitiesSystemPrompt(professionType),
+		Text:         content,
+		ImageUrls:    []string{},
+	}
+
+	opts := models.AIClientOptions{
+		Model:          callModel(callOpts),
+		MaxTokens:      s.cfg.MaxToken,
+		ResponseFormat: models.ResponseFormatJSONSchema,
+		ResponseSchema: models.ExtractEntitiesSchema,
+	}.WithDefaults(s.cfg.DefaultOptions)
+
+	result, err := GenerateInto[extractEntitiesResponse](ctx, s.aiClient, message, opts)
 	if err != nil {
 		return nil, err
 	}
 
+	entities := make([]models.Entity, 0, len(result.Entities))
+	for _, entity := range result.Entities {
+		switch entity.Type {
+		case models.EntityTypeDrug, models.EntityTypeCondition, models.EntityTypeDosage:
+		default:
+			continue
+		}
+		if strings.TrimSpace(entity.Text) == "" {
+			continue
+		}
+		if entity.NormalizedValue != nil && strings.TrimSpace(*entity.NormalizedValue) == "" {
+			entity.NormalizedValue = nil
+		}
+		entities = append(entities, entity)
+	}
+
+	return entities, nil
+}
+
+func (s *articleStore) Polish(ctx context.Context, content string, professionType models.PlatformType, callOpts ...CallOption) (string, error) {
+	text, _, err := s.PolishWithTruncation(ctx, content, professionType, callOpts...)
+	return text, err
+}
+
+// PolishWithTruncation behaves like Polish and also reports whether the
+// content was cut to fit the context window (see ArticleConfig.TruncateInput),
+// so callers can warn the user that only part of the article was polished.
+func (s *articleStore) PolishWithTruncation(ctx context.Context, content string, professionType models.PlatformType, callOpts ...CallOption) (string, bool, error) {
+	systemPrompt := models.GetPolishArticleSystemPrompt(professionType)
+
+	content, opts, truncated, err := s.preparePolish(ctx, content, systemPrompt, callModel(callOpts))
+	if err != nil {
+		return "", false, err
+	}
+	opts.ResponseFormat = models.ResponseFormatText
+
+	message := models.AIChatMessage{
+		SystemPrompt: systemPrompt,
+		Text:         content,
+		ImageUrls:    []string{},
+	}
+
+	resp, err := s.aiClient.Generate(ctx, message, opts)
+	if err != nil {
+		return "", truncated, err
+	}
+
 	if resp == "" {
-		return nil, fmt.Errorf("empty response content from AI client")
+		return "", truncated, fmt.Errorf("empty response content from AI client")
 	}
 
-	var result models.ExtractTagsResult
-	if err := json.Unmarshal([]byte(resp), &result); err != nil {
-		return nil, fmt.Errorf("failed to parse extract tags response: %w", err)
+	return resp, truncated, nil
+}
+
+// PolishWithDiff behaves like Polish and also returns the edits the model
+// made, each with the draft text it replaced and a reason, so editors can
+// review them. Truncated reports whether the content was cut as in
+// PolishWithTruncation. The edits are requested as JSON matching
+// models.PolishResultSchema. When the model answers with plain text instead,
+// that text is returned as the polished article with no changes and
+// Structured set to false.
+func (s *articleStore) PolishWithDiff(ctx context.Context, content string, professionType models.PlatformType, callOpts ...CallOption) (models.PolishResult, error) {
+	systemPrompt := models.GetPolishWithDiffSystemPrompt(professionType)
+
+	content, opts, truncated, err := s.preparePolish(ctx, content, systemPrompt, callModel(callOpts))
+	if err != nil {
+		return models.PolishResult{}, err
 	}
+	opts.ResponseFormat = models.ResponseFormatJSONSchema
+	opts.ResponseSchema = models.PolishResultSchema
 
-	return &result, nil
+	message := models.AIChatMessage{
+		SystemPrompt: systemPrompt,
+		Text:         content,
+		ImageUrls:    []string{},
+	}
+
+	resp, err := s.aiClient.Generate(ctx, message, opts)
+	if err != nil {
+		return models.PolishResult{}, err
+	}
+
+	if strings.TrimSpace(resp) == "" {
+		return models.PolishResult{}, fmt.Errorf("empty response content from AI client")
+	}
+
+	var result models.PolishResult
+	if err := util.UnmarshalJSON(resp, &result); err != nil {
+		logging.Warn(ctx, "polish response has no structured edits, returning it as plain text: %v", err)
+		return models.PolishResult{Text: strings.TrimSpace(resp), Truncated: truncated}, nil
+	}
+	if strings.TrimSpace(result.Text) == "" {
+		return models.PolishResult{}, models.NewInvalidJSONError(resp, errors.New("polished text is missing"))
+	}
+
+	// Drop no-op edits so that Changes lists only real changes.
+	result.Changes = slices.DeleteFunc(result.Changes, func(change models.PolishChange) bool {
+		return change.Original == change.Replacement
+	})
+	result.Structured = true
+	result.Truncated = truncated
+
+	return result, nil
 }
 
-func (s *articleStore) Polish(ctx context.Context, content string, professionType models.PlatformType) (string, error) {
+// preparePolish moderates content and cuts it to fit the context window
+// when configured, returning the content to send, the call options for
+// model and whether it was cut.
+func (s *articleStore) preparePolish(ctx context.Context, content string, systemPrompt string, model string) (string, models.AIClientOptions, bool, error) {
+	if s.aiClient == nil {
+		return "", models.AIClientOptions{}, false, fmt.Errorf("AI client is not initialized")
+	}
+
+	if s.cfg.Moderator != nil {
+		result, err := s.cfg.Moderator.Moderate(ctx, content)
+		if err != nil {
+			return "", models.AIClientOptions{}, false, fmt.Errorf("failed to moderate content: %w", err)
+		}
+		if result.Flagged {
+			return "", models.AIClientOptions{}, false, fmt.Errorf("%w: %s", models.ErrContentFlagged, strings.Join(result.Categories, ", "))
+		}
+	}
+
+	opts := models.AIClientOptions{
+		Model:     model,
+		MaxTokens: s.cfg.MaxToken,
+	}.WithDefaults(s.cfg.DefaultOptions)
+
+	var truncated bool
+	if s.cfg.TruncateInput {
+		contextModel := s.cfg.ContextModel
+		if contextModel == "" {
+			contextModel = opts.Model
+		}
+		promptTokens, err := util.CountTokens(systemPrompt, contextModel)
+		if err != nil {
+			return "", models.AIClientOptions{}, false, err
+		}
+		budget := models.ContextWindow(contextModel) - int(s.cfg.MaxToken) - promptTokens
+		content, truncated = util.TruncateToTokens(content, budget, contextModel)
+	}
+
+	return content, opts, truncated, nil
+}
+
+func (s *articleStore) Translate(ctx context.Context, content string, targetLang string, professionType models.PlatformType, callOpts ...CallOption) (string, error) {
 	if s.aiClient == nil {
 		return "", fmt.Errorf("AI client is not initialized")
 	}
 
-	systemPrompt := models.GetPolishArticleSystemPrompt(professionType)
+	if targetLang == "" {
+		return "", fmt.Errorf("target language cannot be empty")
+	}
+
+	tag, err := language.Parse(targetLang)
+	if err != nil || tag == language.Und {
+		return "", fmt.Errorf("unrecognized target language %q", targetLang)
+	}
+
+	systemPrompt := models.GetTranslateSystemPrompt(professionType, tag.String())
 
 	message := models.AIChatMessage{
 		SystemPrompt: systemPrompt,
@@ -78,10 +282,17 @@ func (s *articleStore) Polish(ctx context.Context, content string, professionTyp
 		ImageUrls:    []string{},
 	}
 
+	// A translation is roughly as long as its source, so size the output
+	// budget from the input instead of relying on the configured default,
+	// up to MaxTranslateTokens.
+	maxTokens := min(int64(utf8.RuneCountInString(content))*2, MaxTranslateTokens)
+	maxTokens = max(maxTokens, s.cfg.MaxToken)
+
 	opts := models.AIClientOptions{
-		MaxTokens:      s.cfg.MaxToken,
+		Model:          callModel(callOpts),
+		MaxTokens:      maxTokens,
 		ResponseFormat: models.ResponseFormatText,
-	}
+	}.WithDefaults(s.cfg.DefaultOptions)
 
 	resp, err := s.aiClient.Generate(ctx, message, opts)
 	if err != nil {
@@ -94,3 +305,83 @@ func (s *articleStore) Polish(ctx context.Context, content string, professionTyp
 
 	return resp, nil
 }
+
+func (s *articleStore) GenerateTitle(ctx context.Context, content string, professionType models.PlatformType, maxChars int, callOpts ...CallOption) (string, error) {
+	if s.aiClient == nil {
+		return "", fmt.Errorf("AI client is not initialized")
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return "", fmt.Errorf("content cannot be empty")
+	}
+
+	if maxChars <= 0 {
+		return "", fmt.Errorf("maxChars must be positive, got %d", maxChars)
+	}
+
+	systemPrompt := models.GetGenerateTitleSystemPrompt(professionType, maxChars)
+
+	message := models.AIChatMessage{
+		SystemPrompt: systemPrompt,
+		Text:         content,
+		ImageUrls:    []string{},
+	}
+
+	opts := models.AIClientOptions{
+		Model:          callModel(callOpts),
+		MaxTokens:      s.cfg.MaxToken,
+		ResponseFormat: models.ResponseFormatText,
+	}.WithDefaults(s.cfg.DefaultOptions)
+
+	resp, err := s.aiClient.Generate(ctx, message, opts)
+	if err != nil {
+		return "", err
+	}
+
+	title := cleanTitle(resp)
+	if title == "" {
+		return "", fmt.Errorf("empty response content from AI client")
+	}
+
+	// The prompt asks for maxChars, but models count loosely.
+	if runes := []rune(title); len(runes) > maxChars {
+		title = strings.TrimSpace(string(runes[:maxChars]))
+	}
+
+	return title, nil
+}
+
+// titleQuotes are the quote marks models tend to wrap a title in.
+const titleQuotes = "\"'`「」『』“”‘’《》"
+
+// cleanTitle keeps the first line of the model output and strips
+// surrounding whitespace and quote marks.
+func cleanTitle(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(text), titleQuotes))
+}
+
+// Moderate checks content with the configured Moderator. Without one it
+// uses the AI client's own moderation when it has any, and otherwise a
+// prompt-based check on the AI client.
+func (s *articleStore) Moderate(ctx context.Context, content string) (models.ModerationResult, error) {
+	if strings.TrimSpace(content) == "" {
+		return models.ModerationResult{}, fmt.Errorf("content cannot be empty")
+	}
+
+	moderator := s.cfg.Moderator
+	if moderator == nil {
+		if s.aiClient == nil {
+			return models.ModerationResult{}, fmt.Errorf("AI client is not initialized")
+		}
+		var ok bool
+		if moderator, ok = s.aiClient.(Moderator); !ok {
+			moderator = NewPromptModerator(s.aiClient)
+		}
+	}
+
+	return moderator.Moderate(ctx, content)
+}
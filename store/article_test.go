@@ -0,0 +1,71 @@
+package store_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/A-pen-app/ai-client/client/clienttest"
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+)
+
+func TestTranslateMaxTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		maxToken int64
+		want     int64
+	}{
+		{name: "short input", content: "你好", maxToken: 2048, want: 2048},
+		{name: "sized from input", content: strings.Repeat("字", 3000), maxToken: 2048, want: 6000},
+		{name: "long input", content: strings.Repeat("字", 100_000), maxToken: 2048, want: store.MaxTranslateTokens},
+		{name: "higher MaxToken", content: strings.Repeat("字", 100_000), maxToken: 32_000, want: 32_000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := clienttest.NewMockClient()
+			mock.EnqueueText("translated")
+			articles := store.NewArticleStore(mock, &store.ArticleConfig{MaxToken: tt.maxToken})
+
+			if _, err := articles.Translate(context.Background(), tt.content, "en", models.PlatformTypeApen); err != nil {
+				t.Fatalf("Translate: %v", err)
+			}
+			if got := mock.LastCall().Options.MaxTokens; got != tt.want {
+				t.Errorf("MaxTokens = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArticleModelPrecedence(t *testing.T) {
+	tests := []struct {
+		name         string
+		defaultModel string
+		callOpts     []store.CallOption
+		want         string
+	}{
+		{name: "client default", want: ""},
+		{name: "store default", defaultModel: "gemini-2.5-flash-lite", want: "gemini-2.5-flash-lite"},
+		{name: "per call", defaultModel: "gemini-2.5-flash-lite", callOpts: []store.CallOption{store.WithModel("gemini-2.5-pro")}, want: "gemini-2.5-pro"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := clienttest.NewMockClient()
+			mock.EnqueueText("A title")
+			articles := store.NewArticleStore(mock, &store.ArticleConfig{
+				MaxToken:       256,
+				DefaultOptions: models.AIClientOptions{Model: tt.defaultModel},
+			})
+
+			if _, err := articles.GenerateTitle(context.Background(), "content", models.PlatformTypeApen, 20, tt.callOpts...); err != nil {
+				t.Fatalf("GenerateTitle: %v", err)
+			}
+			if got := mock.LastCall().Options.Model; got != tt.want {
+				t.Errorf("Model = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/logging"
+)
+
+// RateLimitCoolDown is how long a balanced client skips a client after it
+// reported rate limiting.
+var RateLimitCoolDown = 10 * time.Second
+
+type balancedClient struct {
+	clients []AIClient
+	weights []int
+
+	mu sync.Mutex
+	// current holds the smooth weighted round-robin counters.
+	current      []int
+	limitedUntil []time.Time
+}
+
+// NewBalancedClient returns an AIClient that spreads calls across clients in
+// proportion to weights, e.g. to use several API keys with separate rate
+// limits. Missing or non-positive weights count as 1. A client that answers
+// with models.ErrorKindRateLimited is skipped for RateLimitCoolDown, and the
+// call moves on to the next client; so is a CircuitBreaker whose circuit is
+// open. Other errors are returned as they are.
+func NewBalancedClient(clients []AIClient, weights []int) AIClient {
+	normalized := make([]int, len(clients))
+	for i := range clients {
+		normalized[i] = 1
+		if i < len(weights) && weights[i] > 0 {
+			normalized[i] = weights[i]
+		}
+	}
+
+	return &balancedClient{
+		clients:      clients,
+		weights:      normalized,
+		current:      make([]int, len(clients)),
+		limitedUntil: make([]time.Time, len(clients)),
+	}
+}
+
+func (c *balancedClient) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	var text string
+	err := c.do(ctx, func(client AIClient) error {
+		var err error
+		text, err = client.Generate(ctx, message, opts)
+		return err
+	})
+	return text, err
+}
+
+func (c *balancedClient) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+func (c *balancedClient) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	var text string
+	var usage models.Usage
+	err := c.do(ctx, func(client AIClient) error {
+		var err error
+		text, usage, err = client.GenerateWithUsage(ctx, message, opts)
+		return err
+	})
+	return text, usage, err
+}
+
+func (c *balancedClient) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	var result models.GenerateResult
+	err := c.do(ctx, func(client AIClient) error {
+		var err error
+		result, err = client.GenerateWithTools(ctx, message, opts)
+		return err
+	})
+	return result, err
+}
+
+func (c *balancedClient) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Texts(), nil
+}
+
+// StreamGenerate balances establishing the stream.
+func (c *balancedClient) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	var stream <-chan models.StreamChunk
+	err := c.do(ctx, func(client AIClient) error {
+		var err error
+		stream, err = client.StreamGenerate(ctx, message, opts)
+		return err
+	})
+	return stream, err
+}
+
+// HealthCheck succeeds when any wrapped client is healthy, since calls can
+// then still be served. Otherwise it returns every client's error joined.
+func (c *balancedClient) HealthCheck(ctx context.Context) error {
+	var errs []error
+	for _, client := range c.clients {
+		err := client.HealthCheck(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no clients configured")
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every wrapped client and returns their joined errors.
+func (c *balancedClient) Close() error {
+	var errs []error
+	for _, client := range c.clients {
+		errs = append(errs, client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (c *balancedClient) do(ctx context.Context, fn func(AIClient) error) error {
+	if len(c.clients) == 0 {
+		return fmt.Errorf("no AI clients configured for balancing")
+	}
+
+	tried := make([]bool, len(c.clients))
+	var err error
+	for {
+		i, ok := c.next(tried)
+		if !ok {
+			return err
+		}
+		tried[i] = true
+
+		if err = fn(c.clients[i]); err == nil || models.KindOf(err) != models.ErrorKindRateLimited || ctx.Err() != nil {
+			return err
+		}
+
+		c.markLimited(i)
+		logging.Warn(ctx, "AI client %d is rate limited, skipping it for %s: %v", i, RateLimitCoolDown, err)
+	}
+}
+
+// next picks an untried client by smooth weighted round-robin among those
+// that are available. When every untried client is rate limited or has an
+// open circuit, it picks the one whose rate limit ends first.
+func (c *balancedClient) next(tried []bool) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	best, total := -1, 0
+	for i, client := range c.clients {
+		if tried[i] || now.Before(c.limitedUntil[i]) || circuitOpen(client) {
+			continue
+		}
+		c.current[i] += c.weights[i]
+		total += c.weights[i]
+		if best < 0 || c.current[i] > c.current[best] {
+			best = i
+		}
+	}
+	if best >= 0 {
+		c.current[best] -= total
+		return best, true
+	}
+
+	for i := range c.clients {
+		if !tried[i] && (best < 0 || c.limitedUntil[i].Before(c.limitedUntil[best])) {
+			best = i
+		}
+	}
+	return best, best >= 0
+}
+
+func (c *balancedClient) markLimited(i int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limitedUntil[i] = time.Now().Add(RateLimitCoolDown)
+}
+
+// circuitOpen reports whether client is a circuit breaker that currently
+// fast-fails.
+func circuitOpen(client AIClient) bool {
+	breaker, ok := client.(CircuitBreaker)
+	return ok && breaker.State() == BreakerOpen
+}
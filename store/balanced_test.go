@@ -0,0 +1,100 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/A-pen-app/ai-client/client/clienttest"
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+)
+
+func TestBalancedClientDistribution(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights []int
+	}{
+		{name: "equal", weights: []int{1, 1}},
+		{name: "one to three", weights: []int{1, 3}},
+		{name: "three clients", weights: []int{2, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const rounds = 100
+			total := 0
+			for _, w := range tt.weights {
+				total += w
+			}
+			calls := rounds * total
+
+			mocks := make([]*clienttest.MockClient, len(tt.weights))
+			clients := make([]store.AIClient, len(tt.weights))
+			for i := range mocks {
+				mocks[i] = clienttest.NewMockClient()
+				for range calls {
+					mocks[i].EnqueueText("ok")
+				}
+				clients[i] = mocks[i]
+			}
+			balanced := store.NewBalancedClient(clients, tt.weights)
+
+			for range calls {
+				if _, err := balanced.Generate(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{}); err != nil {
+					t.Fatalf("Generate: %v", err)
+				}
+			}
+
+			for i, mock := range mocks {
+				if got, want := mock.CallCount(), rounds*tt.weights[i]; got != want {
+					t.Errorf("client %d got %d of %d calls, want %d", i, got, calls, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBalancedClientSkipsRateLimitedClient(t *testing.T) {
+	coolDown := store.RateLimitCoolDown
+	store.RateLimitCoolDown = time.Hour
+	t.Cleanup(func() { store.RateLimitCoolDown = coolDown })
+
+	limited := clienttest.NewMockClient()
+	limited.EnqueueError(&models.AIError{Kind: models.ErrorKindRateLimited, StatusCode: 429, Err: errors.New("rate limit exceeded")})
+	healthy := clienttest.NewMockClient()
+	for range 10 {
+		healthy.EnqueueText("ok")
+	}
+	balanced := store.NewBalancedClient([]store.AIClient{limited, healthy}, []int{1, 1})
+
+	for i := range 10 {
+		text, err := balanced.Generate(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{})
+		if err != nil {
+			t.Fatalf("call %d: Generate: %v", i, err)
+		}
+		if text != "ok" {
+			t.Errorf("call %d: Generate = %q, want %q", i, text, "ok")
+		}
+	}
+
+	// The rate-limited client is tried once and then skipped for the
+	// cool-down; its failed call moves on to the healthy client.
+	limited.AssertCallCount(t, 1)
+	healthy.AssertCallCount(t, 10)
+}
+
+func TestBalancedClientReturnsOtherErrors(t *testing.T) {
+	failing := clienttest.NewMockClient()
+	failing.EnqueueError(&models.AIError{Kind: models.ErrorKindInvalidRequest, StatusCode: 400, Err: errors.New("bad request")})
+	healthy := clienttest.NewMockClient()
+	healthy.EnqueueText("ok")
+	balanced := store.NewBalancedClient([]store.AIClient{failing, healthy}, nil)
+
+	_, err := balanced.Generate(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{})
+	if models.KindOf(err) != models.ErrorKindInvalidRequest {
+		t.Errorf("Generate error = %v, want an invalid request error", err)
+	}
+	healthy.AssertCallCount(t, 0)
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// GenerateBatch runs requests through client with at most concurrency calls
+// in flight (1 when concurrency <= 0). Results are returned in input order.
+// Once ctx is done, requests that have not started yet fail with ctx.Err().
+func GenerateBatch(ctx context.Context, client AIClient, requests []models.BatchRequest, concurrency int) []models.BatchResult {
+	results := make([]models.BatchResult, len(requests))
+	sem := make(chan struct{}, max(concurrency, 1))
+
+	var wg sync.WaitGroup
+	for i, req := range requests {
+		results[i].Index = i
+
+		// select picks at random when a slot is free and ctx is done too.
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := ctx.Err(); err != nil {
+				results[i].Err = err
+				return
+			}
+
+			text, usage, err := client.GenerateWithUsage(ctx, req.Message, req.Options)
+			results[i].Text = text
+			results[i].Usage = usage
+			results[i].Err = err
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,53 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/A-pen-app/ai-client/client/clienttest"
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+)
+
+func TestGenerateBatchKeepsOrder(t *testing.T) {
+	mock := clienttest.NewMockClient()
+	requests := make([]models.BatchRequest, 20)
+	for i := range requests {
+		requests[i].Message.Text = fmt.Sprintf("request %d", i)
+		mock.EnqueueText("ok")
+	}
+
+	results := store.GenerateBatch(context.Background(), mock, requests, 4)
+
+	if len(results) != len(requests) {
+		t.Fatalf("GenerateBatch returned %d results, want %d", len(results), len(requests))
+	}
+	for i, result := range results {
+		if result.Index != i || result.Err != nil || result.Text != "ok" {
+			t.Errorf("result %d = %+v, want index %d with text %q", i, result, i, "ok")
+		}
+	}
+	mock.AssertCallCount(t, len(requests))
+}
+
+func TestGenerateBatchCancelledContext(t *testing.T) {
+	mock := clienttest.NewMockClient()
+	requests := make([]models.BatchRequest, 100)
+	for range requests {
+		mock.EnqueueText("ok")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := store.GenerateBatch(ctx, mock, requests, 4)
+
+	for i, result := range results {
+		if result.Index != i || !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("result %d = %+v, want index %d failing with context.Canceled", i, result, i)
+		}
+	}
+	// No call may start once the context is done.
+	mock.AssertCallCount(t, 0)
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// DefaultBestN is the number of candidates GenerateBest asks for when
+// opts.N is 1 or less.
+const DefaultBestN = 3
+
+// GenerateBest asks client for opts.N candidates with GenerateN, or
+// DefaultBestN when opts.N is 1 or less, and returns the one score rates
+// highest. Ties go to the earlier candidate. The provider must support
+// AIClientOptions.N; Anthropic, Bedrock and Ollama return an error.
+func GenerateBest(ctx context.Context, client AIClient, message models.AIChatMessage, opts models.AIClientOptions, score func(string) float64) (string, error) {
+	if score == nil {
+		return "", fmt.Errorf("score function is required")
+	}
+	if opts.N <= 1 {
+		opts.N = DefaultBestN
+	}
+
+	candidates, err := client.GenerateN(ctx, message, opts)
+	if err != nil {
+		return "", err
+	}
+	if len(candidates) == 0 {
+		return "", &models.AIError{Kind: models.ErrorKindEmptyResponse, Err: errors.New("no candidates returned by AI client")}
+	}
+
+	best, bestScore := 0, score(candidates[0])
+	for i, candidate := range candidates[1:] {
+		if s := score(candidate); s > bestScore {
+			best, bestScore = i+1, s
+		}
+	}
+	return candidates[best], nil
+}
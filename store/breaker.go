@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/util"
+)
+
+// ErrCircuitOpen is returned without calling the provider while the circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fast-fails every call with ErrCircuitOpen.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe call through to test recovery.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerConfig controls NewCircuitBreakerClient. Zero values fall back to
+// the defaults noted on each field.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// circuit (default: 5).
+	FailureThreshold int
+	// CoolDown is how long the circuit stays open before a probe call is
+	// allowed (default: 30s).
+	CoolDown time.Duration
+	// IsFailure decides whether an error counts towards the threshold
+	// (default: IsCircuitFailure). Errors returned after the caller's
+	// context is done never count.
+	IsFailure func(error) bool
+}
+
+// CircuitBreaker is an AIClient that also reports its breaker state, e.g.
+// for health endpoints.
+type CircuitBreaker interface {
+	AIClient
+	State() BreakerState
+}
+
+type circuitBreakerClient struct {
+	inner AIClient
+	cfg   BreakerConfig
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreakerClient wraps an AIClient so that a sustained provider
+// outage fast-fails with ErrCircuitOpen instead of sending doomed requests.
+// The circuit opens after cfg.FailureThreshold consecutive failures and
+// allows a single probe once cfg.CoolDown has elapsed; a successful probe
+// closes it again.
+func NewCircuitBreakerClient(inner AIClient, cfg BreakerConfig) CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = 30 * time.Second
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = IsCircuitFailure
+	}
+
+	return &circuitBreakerClient{
+		inner: inner,
+		cfg:   cfg,
+	}
+}
+
+// IsCircuitFailure reports whether err suggests the provider is unhealthy.
+// Context cancellation, invalid requests and content-filter blocks, which
+// the provider answered normally, are not failures. Neither are failed
+// image or media downloads, which happen before the provider is called.
+func IsCircuitFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var downloadErr *util.DownloadError
+	if errors.As(err, &downloadErr) {
+		return false
+	}
+	switch models.KindOf(err) {
+	case models.ErrorKindInvalidRequest, models.ErrorKindContentFiltered:
+		return false
+	}
+	return true
+}
+
+// State returns the current breaker state.
+func (c *circuitBreakerClient) State() BreakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+func (c *circuitBreakerClient) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	var text string
+	err := c.do(ctx, func() error {
+		var err error
+		text, err = c.inner.Generate(ctx, message, opts)
+		return err
+	})
+	return text, err
+}
+
+func (c *circuitBreakerClient) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+func (c *circuitBreakerClient) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	var text string
+	var usage models.Usage
+	err := c.do(ctx, func() error {
+		var err error
+		text, usage, err = c.inner.GenerateWithUsage(ctx, message, opts)
+		return err
+	})
+	return text, usage, err
+}
+
+func (c *circuitBreakerClient) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	var result models.GenerateResult
+	err := c.do(ctx, func() error {
+		var err error
+		result, err = c.inner.GenerateWithTools(ctx, message, opts)
+		return err
+	})
+	return result, err
+}
+
+func (c *circuitBreakerClient) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	var texts []string
+	err := c.do(ctx, func() error {
+		var err error
+		texts, err = c.inner.GenerateN(ctx, message, opts)
+		return err
+	})
+	return texts, err
+}
+
+// StreamGenerate counts only failures to establish the stream.
+func (c *circuitBreakerClient) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	var stream <-chan models.StreamChunk
+	err := c.do(ctx, func() error {
+		var err error
+		stream, err = c.inner.StreamGenerate(ctx, message, opts)
+		return err
+	})
+	return stream, err
+}
+
+// HealthCheck checks the wrapped client.
+func (c *circuitBreakerClient) HealthCheck(ctx context.Context) error {
+	return c.inner.HealthCheck(ctx)
+}
+
+// Close closes the wrapped client.
+func (c *circuitBreakerClient) Close() error {
+	return c.inner.Close()
+}
+
+func (c *circuitBreakerClient) do(ctx context.Context, fn func() error) error {
+	if err := c.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	c.record(ctx, err)
+	return err
+}
+
+// allow reports whether a call may proceed, moving an open circuit to
+// half-open once the cool-down has elapsed.
+func (c *circuitBreakerClient) allow() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.state {
+	case BreakerOpen:
+		if time.Since(c.openedAt) < c.cfg.CoolDown {
+			return ErrCircuitOpen
+		}
+		c.state = BreakerHalfOpen
+	case BreakerHalfOpen:
+		if c.probing {
+			return ErrCircuitOpen
+		}
+	}
+	if c.state == BreakerHalfOpen {
+		c.probing = true
+	}
+	return nil
+}
+
+func (c *circuitBreakerClient) record(ctx context.Context, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.probing = false
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// The caller gave up; this says nothing about the provider.
+	case err != nil && c.cfg.IsFailure(err):
+		c.failures++
+		if c.state == BreakerHalfOpen || c.failures >= c.cfg.FailureThreshold {
+			c.state = BreakerOpen
+			c.openedAt = time.Now()
+		}
+	default:
+		c.state = BreakerClosed
+		c.failures = 0
+	}
+}
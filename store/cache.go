@@ -0,0 +1,285 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// DefaultCacheTTL is how long cached responses live when
+// AIClientOptions.CacheTTL is not set.
+const DefaultCacheTTL = 24 * time.Hour
+
+// Cache stores encoded responses for NewCachingClient.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+type cachingClient struct {
+	inner  AIClient
+	cache  Cache
+	closed atomic.Bool
+}
+
+// NewCachingClient wraps an AIClient so that identical requests are served
+// from cache. Only successful responses are cached, streaming calls are
+// never cached, and a call can opt out with AIClientOptions.NoCache.
+func NewCachingClient(inner AIClient, cache Cache) AIClient {
+	return &cachingClient{
+		inner: inner,
+		cache: cache,
+	}
+}
+
+func (c *cachingClient) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	return result.Text, err
+}
+
+func (c *cachingClient) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+func (c *cachingClient) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	return result.Text, result.Usage, err
+}
+
+func (c *cachingClient) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	if c.closed.Load() {
+		return models.GenerateResult{}, models.ErrClientClosed
+	}
+
+	if opts.NoCache {
+		return c.inner.GenerateWithTools(ctx, message, opts)
+	}
+
+	key, err := cacheKey(message, opts)
+	if err != nil {
+		return c.inner.GenerateWithTools(ctx, message, opts)
+	}
+
+	if data, ok := c.cache.Get(key); ok {
+		var result models.GenerateResult
+		if err := json.Unmarshal(data, &result); err == nil {
+			return result, nil
+		}
+	}
+
+	result, err := c.inner.GenerateWithTools(ctx, message, opts)
+	if err != nil {
+		return result, err
+	}
+
+	if data, err := json.Marshal(result); err == nil {
+		ttl := opts.CacheTTL
+		if ttl <= 0 {
+			ttl = DefaultCacheTTL
+		}
+		c.cache.Set(key, data, ttl)
+	}
+
+	return result, nil
+}
+
+func (c *cachingClient) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Texts(), nil
+}
+
+func (c *cachingClient) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	return c.inner.StreamGenerate(ctx, message, opts)
+}
+
+// HealthCheck checks the wrapped client; it is never served from cache.
+func (c *cachingClient) HealthCheck(ctx context.Context) error {
+	if c.closed.Load() {
+		return models.ErrClientClosed
+	}
+	return c.inner.HealthCheck(ctx)
+}
+
+// Close closes the wrapped client. Cached responses are no longer served
+// afterwards.
+func (c *cachingClient) Close() error {
+	c.closed.Store(true)
+	return c.inner.Close()
+}
+
+// cacheKey returns a stable hash of the parts of a request that affect the
+// response.
+func cacheKey(message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	imageHashes := make([]string, 0, len(message.Images))
+	for _, image := range message.Images {
+		sum := sha256.Sum256(image.Bytes)
+		imageHashes = append(imageHashes, image.MimeType+":"+hex.EncodeToString(sum[:]))
+	}
+
+	audioHashes := make([]string, 0, len(message.Audio))
+	for _, clip := range message.Audio {
+		sum := sha256.Sum256(clip.Bytes)
+		audioHashes = append(audioHashes, clip.MimeType+":"+hex.EncodeToString(sum[:]))
+	}
+
+	data, err := json.Marshal(struct {
+		SystemPrompt     string
+		Text             string
+		ImageUrls        []string
+		Images           []string
+		ImageMimeType    string
+		History          []models.Turn
+		AudioUrls        []string
+		Audio            []string
+		DocumentUrls     []string
+		AssistantPrefill string
+		Model            string
+		MaxTokens        int64
+		ResponseFormat   models.ResponseFormat
+		ResponseSchema   json.RawMessage
+		Temperature      *float64
+		TopP             *float64
+		Tools            []models.Tool
+		ImageDetail      models.ImageDetail
+		Seed             *int64
+		N                int
+		FrequencyPenalty *float64
+		PresencePenalty  *float64
+		SafetySettings   []models.SafetySetting
+		SystemAsUser     bool
+		IncludeRaw       bool
+		ReasoningEffort  models.ReasoningEffort
+		ThinkingBudget   *int
+		IncludeThoughts  bool
+		AdaptiveTokens   bool
+		LogitBias        map[string]int
+		LogProbs         bool
+		TopLogProbs      int
+		CachedContent    string
+	}{
+		SystemPrompt:     message.SystemPrompt,
+		Text:             message.Text,
+		ImageUrls:        message.ImageUrls,
+		Images:           imageHashes,
+		ImageMimeType:    message.ImageMimeType,
+		History:          message.History,
+		AudioUrls:        message.AudioUrls,
+		Audio:            audioHashes,
+		DocumentUrls:     message.DocumentUrls,
+		AssistantPrefill: message.AssistantPrefill,
+		Model:            opts.Model,
+		MaxTokens:        opts.MaxTokens,
+		ResponseFormat:   opts.ResponseFormat,
+		ResponseSchema:   opts.ResponseSchema,
+		Temperature:      opts.Temperature,
+		TopP:             opts.TopP,
+		Tools:            opts.Tools,
+		ImageDetail:      opts.ImageDetail,
+		Seed:             opts.Seed,
+		N:                opts.N,
+		FrequencyPenalty: opts.FrequencyPenalty,
+		PresencePenalty:  opts.PresencePenalty,
+		SafetySettings:   opts.SafetySettings,
+		SystemAsUser:     opts.SystemAsUser,
+		IncludeRaw:       opts.IncludeRawResponse,
+		ReasoningEffort:  opts.ReasoningEffort,
+		ThinkingBudget:   opts.ThinkingBudget,
+		IncludeThoughts:  opts.IncludeThoughts,
+		AdaptiveTokens:   opts.AdaptiveTokens,
+		LogitBias:        opts.LogitBias,
+		LogProbs:         opts.LogProbs,
+		TopLogProbs:      opts.TopLogProbs,
+		CachedContent:    opts.CachedContent,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRUCache is an in-memory Cache that evicts the least recently used entry
+// once it holds capacity entries. It is safe for concurrent use.
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+// NewLRUCache creates an LRUCache holding at most capacity entries.
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+
+	return &LRUCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
@@ -0,0 +1,65 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/A-pen-app/ai-client/client/clienttest"
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+)
+
+func TestCachingClientServesIdenticalRequestsFromCache(t *testing.T) {
+	mock := clienttest.NewMockClient()
+	mock.EnqueueText("first")
+	mock.EnqueueText("second")
+	client := store.NewCachingClient(mock, store.NewLRUCache(10))
+
+	message := models.AIChatMessage{Text: "hi"}
+	opts := models.AIClientOptions{Model: "gemini-2.5-flash"}
+	for i := range 2 {
+		text, err := client.Generate(context.Background(), message, opts)
+		if err != nil {
+			t.Fatalf("call %d: Generate: %v", i, err)
+		}
+		if text != "first" {
+			t.Errorf("call %d: Generate = %q, want %q", i, text, "first")
+		}
+	}
+	mock.AssertCallCount(t, 1)
+}
+
+func TestCachingClientKeyIncludesOptions(t *testing.T) {
+	base := models.AIClientOptions{Model: "gemini-2.5-flash", CachedContent: "cachedContents/a"}
+
+	tests := []struct {
+		name string
+		opts models.AIClientOptions
+	}{
+		{name: "model", opts: models.AIClientOptions{Model: "gemini-2.5-pro", CachedContent: "cachedContents/a"}},
+		{name: "cached content", opts: models.AIClientOptions{Model: "gemini-2.5-flash", CachedContent: "cachedContents/b"}},
+		{name: "no cached content", opts: models.AIClientOptions{Model: "gemini-2.5-flash"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := clienttest.NewMockClient()
+			mock.EnqueueText("first")
+			mock.EnqueueText("second")
+			client := store.NewCachingClient(mock, store.NewLRUCache(10))
+
+			message := models.AIChatMessage{Text: "hi"}
+			if _, err := client.Generate(context.Background(), message, base); err != nil {
+				t.Fatalf("first Generate: %v", err)
+			}
+			text, err := client.Generate(context.Background(), message, tt.opts)
+			if err != nil {
+				t.Fatalf("second Generate: %v", err)
+			}
+			if text != "second" {
+				t.Errorf("Generate = %q, want %q from the client, not the cache", text, "second")
+			}
+			mock.AssertCallCount(t, 2)
+		})
+	}
+}
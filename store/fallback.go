@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/logging"
+)
+
+type fallbackClient struct {
+	clients []AIClient
+}
+
+// NewFallbackClient returns an AIClient that tries each client in order and
+// moves on to the next when a call fails. The last error is returned when
+// every client fails. A done caller context never triggers a fallback, but
+// a per-call request timeout does.
+func NewFallbackClient(clients ...AIClient) AIClient {
+	return &fallbackClient{
+		clients: clients,
+	}
+}
+
+func (c *fallbackClient) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	var text string
+	err := c.do(ctx, func(client AIClient) error {
+		var err error
+		text, err = client.Generate(ctx, message, opts)
+		return err
+	})
+	return text, err
+}
+
+func (c *fallbackClient) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+func (c *fallbackClient) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	var text string
+	var usage models.Usage
+	err := c.do(ctx, func(client AIClient) error {
+		var err error
+		text, usage, err = client.GenerateWithUsage(ctx, message, opts)
+		return err
+	})
+	return text, usage, err
+}
+
+func (c *fallbackClient) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	var result models.GenerateResult
+	err := c.do(ctx, func(client AIClient) error {
+		var err error
+		result, err = client.GenerateWithTools(ctx, message, opts)
+		return err
+	})
+	return result, err
+}
+
+func (c *fallbackClient) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Texts(), nil
+}
+
+// StreamGenerate falls back only while establishing the stream. Errors that
+// surface once chunks have started flowing are delivered on the channel.
+func (c *fallbackClient) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	var stream <-chan models.StreamChunk
+	err := c.do(ctx, func(client AIClient) error {
+		var err error
+		stream, err = client.StreamGenerate(ctx, message, opts)
+		return err
+	})
+	return stream, err
+}
+
+// HealthCheck succeeds when any wrapped client is healthy, since calls can
+// then still be served. Otherwise it returns every client's error joined.
+func (c *fallbackClient) HealthCheck(ctx context.Context) error {
+	var errs []error
+	for _, client := range c.clients {
+		err := client.HealthCheck(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no clients configured")
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every wrapped client and returns their joined errors.
+func (c *fallbackClient) Close() error {
+	var errs []error
+	for _, client := range c.clients {
+		errs = append(errs, client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (c *fallbackClient) do(ctx context.Context, fn func(AIClient) error) error {
+	if len(c.clients) == 0 {
+		return fmt.Errorf("no AI clients configured for fallback")
+	}
+
+	var err error
+	for i, client := range c.clients {
+		if err = fn(client); err == nil {
+			return nil
+		}
+
+		// Only the caller's own context ending stops the fallback. A request
+		// timeout (AIClientOptions.RequestTimeout) also wraps
+		// context.DeadlineExceeded but only means this client hung.
+		if ctx.Err() != nil {
+			return err
+		}
+
+		if i+1 < len(c.clients) {
+			logging.Warn(ctx, "AI client %d failed, falling back to client %d: %v", i, i+1, err)
+		}
+	}
+
+	return err
+}
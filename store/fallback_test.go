@@ -0,0 +1,45 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/A-pen-app/ai-client/client/clienttest"
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+)
+
+func TestFallbackClientFallsBackOnRequestTimeout(t *testing.T) {
+	primary := clienttest.NewMockClient()
+	primary.EnqueueError(requestTimeoutError())
+	secondary := clienttest.NewMockClient()
+	secondary.EnqueueText("ok")
+	client := store.NewFallbackClient(primary, secondary)
+
+	text, err := client.Generate(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if text != "ok" {
+		t.Errorf("Generate = %q, want %q", text, "ok")
+	}
+	primary.AssertCallCount(t, 1)
+	secondary.AssertCallCount(t, 1)
+}
+
+func TestFallbackClientStopsWhenCallerContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	primary := clienttest.NewMockClient()
+	primary.EnqueueText("ok")
+	secondary := clienttest.NewMockClient()
+	secondary.EnqueueText("ok")
+	client := store.NewFallbackClient(primary, secondary)
+
+	if _, err := client.Generate(ctx, models.AIChatMessage{Text: "hi"}, models.AIClientOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Generate error = %v, want context.Canceled", err)
+	}
+	secondary.AssertCallCount(t, 0)
+}
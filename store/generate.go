@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/util"
+)
+
+// GenerateInto asks client for a JSON response and decodes it into a T.
+// opts.ResponseFormat is set to models.ResponseFormatJSON unless it already
+// asks for a JSON schema. The response is repaired with util.RepairJSON
+// first, so Markdown fences and surrounding prose are tolerated. An empty
+// response fails with models.ErrorKindEmptyResponse and undecodable text
+// with models.ErrorKindInvalidJSON, whose RawResponse holds the model output.
+func GenerateInto[T any](ctx context.Context, client AIClient, message models.AIChatMessage, opts models.AIClientOptions) (T, error) {
+	var result T
+
+	if opts.ResponseFormat != models.ResponseFormatJSONSchema {
+		opts.ResponseFormat = models.ResponseFormatJSON
+	}
+
+	resp, err := client.Generate(ctx, message, opts)
+	if err != nil {
+		return result, err
+	}
+
+	if resp == "" {
+		return result, &models.AIError{Kind: models.ErrorKindEmptyResponse, Err: errors.New("empty response content from AI client")}
+	}
+
+	if err := util.UnmarshalJSON(resp, &result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,112 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/A-pen-app/ai-client/client/clienttest"
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+)
+
+type nameCard struct {
+	Name  string   `json:"name"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+}
+
+func TestGenerateInto(t *testing.T) {
+	want := nameCard{Name: "王小明", Title: "主治醫師", Tags: []string{"內科"}}
+
+	tests := []struct {
+		name string
+		text string
+	}{
+		{name: "clean JSON", text: `{"name": "王小明", "title": "主治醫師", "tags": ["內科"]}`},
+		{name: "fenced JSON", text: "```json\n{\"name\": \"王小明\", \"title\": \"主治醫師\", \"tags\": [\"內科\"]}\n```"},
+		{name: "fenced JSON with prose and trailing commas", text: "Here you go:\n```json\n{\"name\": \"王小明\", \"title\": \"主治醫師\", \"tags\": [\"內科\",],}\n```"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := clienttest.NewMockClient()
+			mock.EnqueueText(tt.text)
+
+			got, err := store.GenerateInto[nameCard](context.Background(), mock, models.AIChatMessage{Text: "Read this card."}, models.AIClientOptions{})
+			if err != nil {
+				t.Fatalf("GenerateInto: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("GenerateInto = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestGenerateIntoForcesJSON(t *testing.T) {
+	schema := json.RawMessage(`{"type": "object"}`)
+
+	tests := []struct {
+		name   string
+		opts   models.AIClientOptions
+		format models.ResponseFormat
+	}{
+		{name: "unset", opts: models.AIClientOptions{}, format: models.ResponseFormatJSON},
+		{name: "text", opts: models.AIClientOptions{ResponseFormat: models.ResponseFormatText}, format: models.ResponseFormatJSON},
+		{name: "JSON schema kept", opts: models.AIClientOptions{ResponseFormat: models.ResponseFormatJSONSchema, ResponseSchema: schema}, format: models.ResponseFormatJSONSchema},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := clienttest.NewMockClient()
+			mock.EnqueueText(`{"name": "王小明"}`)
+
+			if _, err := store.GenerateInto[nameCard](context.Background(), mock, models.AIChatMessage{Text: "Read this card."}, tt.opts); err != nil {
+				t.Fatalf("GenerateInto: %v", err)
+			}
+			if got := mock.LastCall().Options.ResponseFormat; got != tt.format {
+				t.Errorf("ResponseFormat = %q, want %q", got, tt.format)
+			}
+		})
+	}
+}
+
+func TestGenerateIntoEmptyResponse(t *testing.T) {
+	mock := clienttest.NewMockClient()
+	mock.EnqueueText("")
+
+	_, err := store.GenerateInto[nameCard](context.Background(), mock, models.AIChatMessage{Text: "Read this card."}, models.AIClientOptions{})
+	if models.KindOf(err) != models.ErrorKindEmptyResponse {
+		t.Errorf("GenerateInto error = %v, want an empty response error", err)
+	}
+}
+
+func TestGenerateIntoInvalidJSON(t *testing.T) {
+	const text = "I'm sorry, I cannot read the text on this card."
+	mock := clienttest.NewMockClient()
+	mock.EnqueueText(text)
+
+	_, err := store.GenerateInto[nameCard](context.Background(), mock, models.AIChatMessage{Text: "Read this card."}, models.AIClientOptions{})
+
+	var aiErr *models.AIError
+	if !errors.As(err, &aiErr) || aiErr.Kind != models.ErrorKindInvalidJSON {
+		t.Fatalf("GenerateInto error = %v, want an invalid JSON error", err)
+	}
+	if aiErr.RawResponse != text {
+		t.Errorf("RawResponse = %q, want %q", aiErr.RawResponse, text)
+	}
+}
+
+func TestGenerateIntoClientError(t *testing.T) {
+	wantErr := &models.AIError{Kind: models.ErrorKindRateLimited, StatusCode: 429, Err: errors.New("rate limit exceeded")}
+	mock := clienttest.NewMockClient()
+	mock.EnqueueError(wantErr)
+
+	_, err := store.GenerateInto[nameCard](context.Background(), mock, models.AIChatMessage{Text: "Read this card."}, models.AIClientOptions{})
+	if !errors.Is(err, wantErr) {
+		t.Errorf("GenerateInto error = %v, want %v", err, wantErr)
+	}
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/logging"
+)
+
+// LogLevel selects how much a logging client logs.
+type LogLevel int
+
+const (
+	// LogLevelErrors logs failed calls only. It is the default and keeps
+	// production quiet.
+	LogLevelErrors LogLevel = iota
+	// LogLevelInfo also logs the metadata of successful calls: model,
+	// attachment counts, latency and token usage.
+	LogLevelInfo
+	// LogLevelVerbose also logs the truncated prompt and response text.
+	// Use it outside production only.
+	LogLevelVerbose
+)
+
+// LogOptions controls NewLoggingClient.
+type LogOptions struct {
+	Level LogLevel
+	// MaxTextLength truncates logged prompt and response text to this many
+	// bytes (default: 500).
+	MaxTextLength int
+	// RedactSystemPrompt replaces the system prompt with a placeholder in
+	// verbose logs.
+	RedactSystemPrompt bool
+}
+
+const redacted = "[redacted]"
+
+type loggingClient struct {
+	inner AIClient
+	opts  LogOptions
+}
+
+// NewLoggingClient wraps an AIClient so that calls are logged through the
+// logging package. Image and audio URLs and bytes are never logged, only
+// their counts.
+func NewLoggingClient(inner AIClient, opts LogOptions) AIClient {
+	if opts.MaxTextLength <= 0 {
+		opts.MaxTextLength = 500
+	}
+
+	return &loggingClient{
+		inner: inner,
+		opts:  opts,
+	}
+}
+
+func (c *loggingClient) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	start := time.Now()
+	text, err := c.inner.Generate(ctx, message, opts)
+	c.log(ctx, "Generate", message, opts, start, text, models.Usage{}, err)
+	return text, err
+}
+
+// GenerateChat is recorded as a Generate call.
+func (c *loggingClient) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+func (c *loggingClient) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	start := time.Now()
+	text, usage, err := c.inner.GenerateWithUsage(ctx, message, opts)
+	c.log(ctx, "GenerateWithUsage", message, opts, start, text, usage, err)
+	return text, usage, err
+}
+
+func (c *loggingClient) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	start := time.Now()
+	result, err := c.inner.GenerateWithTools(ctx, message, opts)
+	c.log(ctx, "GenerateWithTools", message, opts, start, result.Text, result.Usage, err)
+	return result, err
+}
+
+// GenerateN logs the first candidate as the response.
+func (c *loggingClient) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	start := time.Now()
+	texts, err := c.inner.GenerateN(ctx, message, opts)
+	var text string
+	if len(texts) > 0 {
+		text = texts[0]
+	}
+	c.log(ctx, "GenerateN", message, opts, start, text, models.Usage{}, err)
+	return texts, err
+}
+
+// StreamGenerate logs establishing the stream only.
+func (c *loggingClient) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	start := time.Now()
+	stream, err := c.inner.StreamGenerate(ctx, message, opts)
+	c.log(ctx, "StreamGenerate", message, opts, start, "", models.Usage{}, err)
+	return stream, err
+}
+
+func (c *loggingClient) HealthCheck(ctx context.Context) error {
+	err := c.inner.HealthCheck(ctx)
+	if err != nil {
+		logging.Errorw(ctx, "AI health check failed", withCorrelationID(ctx, "error", err, "error_kind", models.KindOf(err))...)
+	}
+	return err
+}
+
+// Close closes the wrapped client.
+func (c *loggingClient) Close() error {
+	return c.inner.Close()
+}
+
+func (c *loggingClient) log(ctx context.Context, method string, message models.AIChatMessage, opts models.AIClientOptions, start time.Time, response string, usage models.Usage, err error) {
+	if err == nil && c.opts.Level < LogLevelInfo {
+		return
+	}
+
+	keysAndValues := []interface{}{
+		"method", method,
+		"model", opts.Model,
+		"image_count", len(message.ImageUrls) + len(message.Images),
+		"audio_count", len(message.AudioUrls) + len(message.Audio),
+		"document_count", len(message.DocumentUrls),
+		"history_turns", len(message.History),
+		"latency_ms", time.Since(start).Milliseconds(),
+	}
+	if usage != (models.Usage{}) {
+		keysAndValues = append(keysAndValues,
+			"prompt_tokens", usage.PromptTokens,
+			"completion_tokens", usage.CompletionTokens,
+			"cached_prompt_tokens", usage.CachedPromptTokens,
+		)
+	}
+
+	if c.opts.Level >= LogLevelVerbose {
+		systemPrompt := c.truncate(message.SystemPrompt)
+		if c.opts.RedactSystemPrompt && message.SystemPrompt != "" {
+			systemPrompt = redacted
+		}
+		keysAndValues = append(keysAndValues,
+			"system_prompt", systemPrompt,
+			"prompt", c.truncate(message.Text),
+			"response", c.truncate(response),
+		)
+	}
+
+	keysAndValues = withCorrelationID(ctx, keysAndValues...)
+	if err != nil {
+		keysAndValues = append(keysAndValues, "error", err, "error_kind", models.KindOf(err))
+		logging.Errorw(ctx, "AI call failed", keysAndValues...)
+		return
+	}
+	logging.Infow(ctx, "AI call", keysAndValues...)
+}
+
+// withCorrelationID appends the correlation ID set on ctx with
+// models.WithCorrelationID, if any, to keysAndValues.
+func withCorrelationID(ctx context.Context, keysAndValues ...interface{}) []interface{} {
+	if id := models.CorrelationID(ctx); id != "" {
+		keysAndValues = append(keysAndValues, "correlation_id", id)
+	}
+	return keysAndValues
+}
+
+// truncate shortens text to MaxTextLength bytes without splitting a rune.
+func (c *loggingClient) truncate(text string) string {
+	if len(text) <= c.opts.MaxTextLength {
+		return text
+	}
+	cut := c.opts.MaxTextLength
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "...(truncated)"
+}
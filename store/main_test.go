@@ -0,0 +1,16 @@
+package store_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/A-pen-app/logging"
+)
+
+func TestMain(m *testing.M) {
+	// The wrappers log warnings, which need an initialized logger.
+	if err := logging.Initialize(&logging.Config{Level: logging.LevelError}); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,173 @@
+// Package metrics records Prometheus metrics for the calls made through an
+// AIClient: ai_client_requests_total counts calls, labeled by outcome
+// ("success", "canceled" or the models.ErrorKind of the error);
+// ai_client_request_duration_seconds is their latency; and
+// ai_client_tokens_total adds up the reported usage, labeled by type
+// (prompt, completion or cached_prompt). Every metric is also labeled by
+// provider, model ("default" when the call sets none) and, except for
+// tokens, method.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type collectors struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	tokens   *prometheus.CounterVec
+}
+
+type metricsClient struct {
+	inner    store.AIClient
+	provider string
+	metrics  *collectors
+}
+
+// NewMetricsClient wraps an AIClient so that every call records its latency,
+// a request count labeled by outcome, and the reported token usage, all
+// labeled by provider, model and method. Several clients may share one
+// registerer; the collectors are registered once and reused.
+func NewMetricsClient(inner store.AIClient, registerer prometheus.Registerer, provider string) (store.AIClient, error) {
+	c := &collectors{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ai_client_requests_total",
+			Help: "AI client calls by provider, model, method and outcome.",
+		}, []string{"provider", "model", "method", "outcome"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ai_client_request_duration_seconds",
+			Help:    "AI client call latency by provider, model and method.",
+			Buckets: []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 40, 80},
+		}, []string{"provider", "model", "method"}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ai_client_tokens_total",
+			Help: "Tokens consumed by provider, model and type (prompt, completion, or cached_prompt, the part of prompt served from the provider's prompt cache).",
+		}, []string{"provider", "model", "type"}),
+	}
+
+	var err error
+	if c.requests, err = register(registerer, c.requests); err != nil {
+		return nil, err
+	}
+	if c.latency, err = register(registerer, c.latency); err != nil {
+		return nil, err
+	}
+	if c.tokens, err = register(registerer, c.tokens); err != nil {
+		return nil, err
+	}
+
+	return &metricsClient{
+		inner:    inner,
+		provider: provider,
+		metrics:  c,
+	}, nil
+}
+
+// register registers collector, returning the already registered one when
+// an identical collector exists.
+func register[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	if err := registerer.Register(collector); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			if existing, ok := already.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return collector, err
+	}
+	return collector, nil
+}
+
+func (c *metricsClient) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	start := time.Now()
+	text, err := c.inner.Generate(ctx, message, opts)
+	c.observe("Generate", opts, start, models.Usage{}, err)
+	return text, err
+}
+
+// GenerateChat is recorded as a Generate call.
+func (c *metricsClient) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+func (c *metricsClient) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	start := time.Now()
+	text, usage, err := c.inner.GenerateWithUsage(ctx, message, opts)
+	c.observe("GenerateWithUsage", opts, start, usage, err)
+	return text, usage, err
+}
+
+func (c *metricsClient) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	start := time.Now()
+	result, err := c.inner.GenerateWithTools(ctx, message, opts)
+	c.observe("GenerateWithTools", opts, start, result.Usage, err)
+	return result, err
+}
+
+func (c *metricsClient) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	start := time.Now()
+	texts, err := c.inner.GenerateN(ctx, message, opts)
+	c.observe("GenerateN", opts, start, models.Usage{}, err)
+	return texts, err
+}
+
+// StreamGenerate measures establishing the stream only.
+func (c *metricsClient) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	start := time.Now()
+	stream, err := c.inner.StreamGenerate(ctx, message, opts)
+	c.observe("StreamGenerate", opts, start, models.Usage{}, err)
+	return stream, err
+}
+
+func (c *metricsClient) HealthCheck(ctx context.Context) error {
+	start := time.Now()
+	err := c.inner.HealthCheck(ctx)
+	c.observe("HealthCheck", models.AIClientOptions{}, start, models.Usage{}, err)
+	return err
+}
+
+// Close closes the wrapped client.
+func (c *metricsClient) Close() error {
+	return c.inner.Close()
+}
+
+func (c *metricsClient) observe(method string, opts models.AIClientOptions, start time.Time, usage models.Usage, err error) {
+	model := opts.Model
+	if model == "" {
+		model = "default"
+	}
+
+	c.metrics.latency.WithLabelValues(c.provider, model, method).Observe(time.Since(start).Seconds())
+	c.metrics.requests.WithLabelValues(c.provider, model, method, outcome(err)).Inc()
+
+	if usage.PromptTokens > 0 {
+		c.metrics.tokens.WithLabelValues(c.provider, model, "prompt").Add(float64(usage.PromptTokens))
+	}
+	if usage.CompletionTokens > 0 {
+		c.metrics.tokens.WithLabelValues(c.provider, model, "completion").Add(float64(usage.CompletionTokens))
+	}
+	if usage.CachedPromptTokens > 0 {
+		c.metrics.tokens.WithLabelValues(c.provider, model, "cached_prompt").Add(float64(usage.CachedPromptTokens))
+	}
+}
+
+// outcome maps err onto a small fixed set of label values.
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return string(models.KindOf(err))
+}
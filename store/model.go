@@ -0,0 +1,29 @@
+package store
+
+// CallOption changes a single store call, such as ScanRawInfo or Polish.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	model string
+}
+
+// WithModel makes a store call use model. The model for a store call is, in
+// order of precedence: the one set with WithModel, the store's
+// DefaultOptions.Model, and the AIClient's default model. This lets one
+// AIClient serve a vision model for OCR and a cheaper text model for
+// articles, and a single call use a stronger model.
+func WithModel(model string) CallOption {
+	return func(o *callOptions) {
+		o.model = model
+	}
+}
+
+// callModel returns the model set with WithModel among opts, or "" to fall
+// back to the store default.
+func callModel(opts []CallOption) string {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o.model
+}
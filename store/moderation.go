@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// Moderator checks user content against content policy. The OpenAI client
+// implements it with the moderation endpoint and the Gemini client with a
+// prompt; NewPromptModerator works on top of any AIClient.
+type Moderator interface {
+	Moderate(ctx context.Context, content string) (models.ModerationResult, error)
+}
+
+// moderationThreshold is the score at which a prompt-based moderator flags
+// a category.
+const moderationThreshold = 0.5
+
+const moderationPrompt = `You are a content moderator for a professional medical community. Classify the user's content for policy violations in these categories: %s.
+Clinical discussion of medication, injuries, anatomy or self-harm risk in a professional context is not a violation.
+Respond with a JSON object with a "flagged" boolean and a "scores" object mapping every category to a number between 0 and 1.`
+
+type promptModerator struct {
+	client AIClient
+}
+
+// moderationVerdict is the JSON the moderation prompt asks for.
+type moderationVerdict struct {
+	Flagged bool               `json:"flagged"`
+	Scores  map[string]float64 `json:"scores"`
+}
+
+// NewPromptModerator returns a Moderator that asks client to classify the
+// content. Categories scoring at least 0.5 are flagged.
+func NewPromptModerator(client AIClient) Moderator {
+	return &promptModerator{client: client}
+}
+
+func (m *promptModerator) Moderate(ctx context.Context, content string) (models.ModerationResult, error) {
+	if m.client == nil {
+		return models.ModerationResult{}, fmt.Errorf("AI client is not initialized")
+	}
+
+	message := models.AIChatMessage{
+		SystemPrompt: fmt.Sprintf(moderationPrompt, strings.Join(models.ModerationCategories, ", ")),
+		Text:         content,
+	}
+
+	temperature := 0.0
+	opts := models.AIClientOptions{
+		ResponseFormat: models.ResponseFormatJSON,
+		Temperature:    &temperature,
+	}
+
+	verdict, err := GenerateInto[moderationVerdict](ctx, m.client, message, opts)
+	if err != nil {
+		return models.ModerationResult{}, err
+	}
+
+	return moderationResult(verdict.Flagged, verdict.Scores), nil
+}
+
+// moderationResult flags the categories scoring at least
+// moderationThreshold.
+func moderationResult(flagged bool, scores map[string]float64) models.ModerationResult {
+	result := models.ModerationResult{Scores: scores}
+	for category, score := range scores {
+		if score >= moderationThreshold {
+			result.Categories = append(result.Categories, category)
+		}
+	}
+	slices.Sort(result.Categories)
+	result.Flagged = flagged || len(result.Categories) > 0
+	return result
+}
@@ -3,18 +3,70 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/util"
 	"github.com/A-pen-app/logging"
 	"github.com/A-pen-app/mq/v2"
 	"github.com/tidwall/sjson"
+	"golang.org/x/text/language"
 )
 
 type Config struct {
 	MaxToken int64
-	IsProd   bool
+	// IsProd selects the default Topic when Topic is empty.
+	IsProd bool
+	// Topic is the MQ topic OCR events are sent to (default:
+	// models.OCRTopicProd when IsProd, models.OCRTopicDev otherwise).
+	Topic models.OCRTopic
+	// MessageType is the type of the OCR events (default:
+	// models.OCRMessageTypeIdentifyOCR).
+	MessageType models.OCRMessageType
+	// DryRun skips publishing OCR events to the MQ. Results are still
+	// parsed and returned as usual.
+	DryRun bool
+	// DefaultOptions applies to every call, e.g. Model, Temperature or
+	// RequestTimeout. MaxToken, the response format each method needs and
+	// a model set with WithModel take precedence over it.
+	DefaultOptions models.AIClientOptions
+	// ScanConcurrency is how many images ScanNames scans at once
+	// (default: 4).
+	ScanConcurrency int
+	// RepairJSON cleans up the model's raw response before ScanName,
+	// ScanNames, ScanField and ScanRawInfo decode it, e.g. to plug in a
+	// dedicated JSON repair library. An error fails the scan with a
+	// models.ErrorKindInvalidJSON error. Defaults to util.RepairJSON, which
+	// strips Markdown fences, surrounding prose and trailing commas.
+	RepairJSON func(raw string) (string, error)
+	// SendRetries is how many times a failed OCR event send is retried,
+	// with exponential backoff starting at SendRetryBackoff (default:
+	// 500ms). The default of 0 sends once.
+	SendRetries      int
+	SendRetryBackoff time.Duration
+	// OnSendError is called with the event when sending it failed for good,
+	// e.g. to write it to a dead-letter store for later replay.
+	OnSendError func(ctx context.Context, event models.OCREventMessage, err error)
+	// ReturnSendError makes ScanRawInfo fail with the send error. By
+	// default the error is only logged and the scan result is returned.
+	ReturnSendError bool
+	// IncludeIdentifyURLs adds identify_urls, the list of every scanned
+	// link, to the payload of single-image scans too. Multi-image scans
+	// always include it. identify_url, the first link, is always set.
+	IncludeIdentifyURLs bool
+	// IncludeScannedAt adds scanned_at, when the scan completed, to the
+	// payload.
+	IncludeScannedAt bool
+	// OutputLanguage asks ScanRawInfo and ScanRawInfoMulti for the text
+	// fields in another language, e.g. models.OutputLanguageRomanized for
+	// romanized names or a BCP-47 code such as "en". The default,
+	// models.OutputLanguageOriginal, keeps the language of the document.
+	OutputLanguage models.OutputLanguage
 }
 
 type ocrStore struct {
@@ -31,96 +83,341 @@ func NewOcrStore(mq mq.MQ, aiClient AIClient, config *Config) OCR {
 		}
 	}
 
+	cfg := *config
+	if cfg.Topic == "" {
+		cfg.Topic = models.OCRTopicDev
+		if cfg.IsProd {
+			cfg.Topic = models.OCRTopicProd
+		}
+	}
+	if cfg.MessageType == "" {
+		cfg.MessageType = models.OCRMessageTypeIdentifyOCR
+	}
+	if cfg.ScanConcurrency <= 0 {
+		cfg.ScanConcurrency = 4
+	}
+	if cfg.SendRetryBackoff <= 0 {
+		cfg.SendRetryBackoff = 500 * time.Millisecond
+	}
+	if cfg.RepairJSON == nil {
+		cfg.RepairJSON = func(raw string) (string, error) {
+			return util.RepairJSON(raw), nil
+		}
+	}
+
 	return &ocrStore{
 		mq:       mq,
 		aiClient: aiClient,
-		cfg:      config,
+		cfg:      &cfg,
+	}
+}
+
+func (s *ocrStore) ScanName(ctx context.Context, link string, callOpts ...CallOption) (string, error) {
+	if s.aiClient == nil {
+		return "", fmt.Errorf("AI client is not initialized")
+	}
+
+	req := s.nameRequest(link, callModel(callOpts))
+	resp, err := s.aiClient.Generate(ctx, req.Message, req.Options)
+	if err != nil {
+		return "", err
+	}
+
+	return s.parseName(resp)
+}
+
+// ScanNames scans several badge photos concurrently, up to
+// Config.ScanConcurrency at a time. Results are in link order and carry
+// their own error, so one unreadable photo does not fail the batch. The
+// error is only set when the store itself cannot scan.
+func (s *ocrStore) ScanNames(ctx context.Context, links []string, callOpts ...CallOption) ([]models.ScanNameResult, error) {
+	if s.aiClient == nil {
+		return nil, fmt.Errorf("AI client is not initialized")
+	}
+
+	model := callModel(callOpts)
+	requests := make([]models.BatchRequest, len(links))
+	for i, link := range links {
+		requests[i] = s.nameRequest(link, model)
+	}
+
+	results := make([]models.ScanNameResult, len(links))
+	for i, batchResult := range GenerateBatch(ctx, s.aiClient, requests, s.cfg.ScanConcurrency) {
+		results[i].Link = links[i]
+		if batchResult.Err != nil {
+			results[i].Err = batchResult.Err
+			continue
+		}
+		results[i].Name, results[i].Err = s.parseName(batchResult.Text)
+	}
+
+	return results, nil
+}
+
+// nameRequest builds the ScanName request for the image at link, using
+// model when it is set.
+func (s *ocrStore) nameRequest(link string, model string) models.BatchRequest {
+	return models.BatchRequest{
+		Message: models.AIChatMessage{
+			SystemPrompt: models.SystemContent,
+			Text:         models.NamePrompt,
+			ImageUrls:    []string{link},
+		},
+		Options: models.AIClientOptions{
+			Model:          model,
+			MaxTokens:      s.cfg.MaxToken,
+			ResponseFormat: models.ResponseFormatJSON,
+		}.WithDefaults(s.cfg.DefaultOptions),
+	}
+}
+
+// parseName extracts the name from a ScanName response.
+func (s *ocrStore) parseName(resp string) (string, error) {
+	repaired, err := s.repairJSON(resp)
+	if err != nil {
+		return "", err
+	}
+
+	result := struct {
+		Name string `json:"name"`
+	}{}
+
+	if err := json.Unmarshal([]byte(repaired), &result); err != nil {
+		return "", models.NewInvalidJSONError(resp, err)
+	}
+
+	return result.Name, nil
+}
+
+// repairJSON runs Config.RepairJSON on raw and checks that the result is
+// valid JSON.
+func (s *ocrStore) repairJSON(raw string) (string, error) {
+	repaired, err := s.cfg.RepairJSON(raw)
+	if err != nil {
+		return "", models.NewInvalidJSONError(raw, fmt.Errorf("failed to repair JSON: %w", err))
 	}
+	if !json.Valid([]byte(repaired)) {
+		return "", models.NewInvalidJSONError(raw, errors.New("response is not valid JSON"))
+	}
+	return repaired, nil
 }
 
-func (s *ocrStore) ScanName(ctx context.Context, link string) (string, error) {
+// ScanField re-scans the image at link for a single field, such as
+// "license_number" or a field of models.OCRRawInfo, with a prompt focused
+// on that field. Use it to re-query a field the full scan got wrong without
+// repeating the whole scan. It returns "" when the field is not found. No
+// OCR event is sent.
+func (s *ocrStore) ScanField(ctx context.Context, link string, field string, platformType models.PlatformType, callOpts ...CallOption) (string, error) {
 	if s.aiClient == nil {
 		return "", fmt.Errorf("AI client is not initialized")
 	}
 
+	if strings.TrimSpace(field) == "" {
+		return "", fmt.Errorf("field cannot be empty")
+	}
+
+	imageUrls, documentUrls := splitDocuments([]string{link})
 	message := models.AIChatMessage{
 		SystemPrompt: models.SystemContent,
-		Text:         models.NamePrompt,
-		ImageUrls:    []string{link},
+		Text:         models.GetFieldPrompt(field, platformType),
+		ImageUrls:    imageUrls,
+		DocumentUrls: documentUrls,
 	}
 
 	opts := models.AIClientOptions{
+		Model:          callModel(callOpts),
 		MaxTokens:      s.cfg.MaxToken,
 		ResponseFormat: models.ResponseFormatJSON,
-	}
+	}.WithDefaults(s.cfg.DefaultOptions)
 
 	resp, err := s.aiClient.Generate(ctx, message, opts)
 	if err != nil {
 		return "", err
 	}
 
+	repaired, err := s.repairJSON(resp)
+	if err != nil {
+		return "", err
+	}
+
 	result := struct {
-		Name string `json:"name"`
+		Value *string `json:"value"`
 	}{}
 
-	if err := json.Unmarshal([]byte(resp), &result); err != nil {
-		return "", err
+	if err := json.Unmarshal([]byte(repaired), &result); err != nil {
+		return "", models.NewInvalidJSONError(resp, err)
 	}
 
-	return result.Name, nil
+	if result.Value == nil {
+		return "", nil
+	}
+	return strings.TrimSpace(*result.Value), nil
 }
 
-func (s *ocrStore) ScanRawInfo(ctx context.Context, userID string, link string, platformType models.PlatformType) (*models.OCRRawInfo, error) {
+func (s *ocrStore) ScanRawInfo(ctx context.Context, userID string, link string, platformType models.PlatformType, callOpts ...CallOption) (*models.OCRRawInfo, error) {
+	return s.scanRawInfo(ctx, userID, []string{link}, platformType, callModel(callOpts))
+}
+
+// ScanRawInfoMulti scans several images of the same document, e.g. the
+// front and back of a license, in a single call so the model reasons over
+// them jointly. The event payload lists every link in identify_urls.
+func (s *ocrStore) ScanRawInfoMulti(ctx context.Context, userID string, links []string, platformType models.PlatformType, callOpts ...CallOption) (*models.OCRRawInfo, error) {
+	if len(links) == 0 {
+		return nil, fmt.Errorf("at least one image link is required")
+	}
+	return s.scanRawInfo(ctx, userID, links, platformType, callModel(callOpts))
+}
+
+func (s *ocrStore) scanRawInfo(ctx context.Context, userID string, links []string, platformType models.PlatformType, model string) (*models.OCRRawInfo, error) {
 	if s.aiClient == nil {
 		return nil, fmt.Errorf("AI client is not initialized")
 	}
 
-	prompt := models.GetInfoPrompt(platformType)
+	if lang := s.cfg.OutputLanguage; lang != models.OutputLanguageOriginal && lang != models.OutputLanguageRomanized {
+		if tag, err := language.Parse(string(lang)); err != nil || tag == language.Und {
+			return nil, fmt.Errorf("unrecognized output language %q", lang)
+		}
+	}
+
+	prompt := models.GetInfoPrompt(platformType, s.cfg.OutputLanguage)
 
+	imageUrls, documentUrls := splitDocuments(links)
 	message := models.AIChatMessage{
 		SystemPrompt: models.SystemContent,
 		Text:         prompt,
-		ImageUrls:    []string{link},
+		ImageUrls:    imageUrls,
+		DocumentUrls: documentUrls,
 	}
 
 	opts := models.AIClientOptions{
+		Model:          model,
 		MaxTokens:      s.cfg.MaxToken,
 		ResponseFormat: models.ResponseFormatJSON,
-	}
+	}.WithDefaults(s.cfg.DefaultOptions)
 
-	resp, err := s.aiClient.Generate(ctx, message, opts)
+	start := time.Now()
+	result, err := s.aiClient.GenerateWithTools(ctx, message, opts)
 	if err != nil {
 		return nil, err
 	}
+	latency := time.Since(start)
 
-	if resp == "" {
+	if result.FinishReason == models.FinishReasonLength {
+		logging.Errorw(ctx, "Truncated ocr output", withCorrelationID(ctx,
+			"max_tokens", s.cfg.MaxToken,
+			"user_id", userID,
+			"model", opts.Model,
+			"latency_ms", latency.Milliseconds(),
+		)...)
+		return nil, fmt.Errorf("truncated output: response reached the %d token limit", s.cfg.MaxToken)
+	}
+
+	if result.Text == "" {
 		return nil, fmt.Errorf("empty response content from AI client")
 	}
 
-	modifiedJSON, err := sjson.Set(resp, "identify_url", link)
+	resp, err := s.repairJSON(result.Text)
 	if err != nil {
 		return nil, err
 	}
 
-	ocrTopic := models.OCRTopicDev
-	if s.cfg.IsProd {
-		ocrTopic = models.OCRTopicProd
+	modifiedJSON, err := sjson.Set(resp, "identify_url", links[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(links) > 1 || s.cfg.IncludeIdentifyURLs {
+		modifiedJSON, err = sjson.Set(modifiedJSON, "identify_urls", links)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if err := s.mq.Send(string(ocrTopic), models.OCREventMessage{
-		UserID:    userID,
-		Payload:   modifiedJSON,
-		CreatedAt: time.Now(),
-		Type:      string(models.OCRMessageTypeIdentifyOCR),
-		Source:    string(platformType),
-	}); err != nil {
-		logging.Errorw(ctx, "Failed to send ocr result", "error", err)
+	scannedAt := time.Now()
+	if s.cfg.IncludeScannedAt {
+		modifiedJSON, err = sjson.Set(modifiedJSON, "scanned_at", scannedAt)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	// Parse before publishing, so that a payload that does not fit
+	// OCRRawInfo never reaches consumers.
 	ocr := models.OCRRawInfo{}
 	if err := json.Unmarshal([]byte(modifiedJSON), &ocr); err != nil {
-		return nil, err
+		return nil, models.NewInvalidJSONError(result.Text, err)
+	}
+
+	if s.cfg.DryRun {
+		logging.Debug(ctx, "Dry run, skipped sending ocr result to %s", s.cfg.Topic)
+	} else {
+		event := models.OCREventMessage{
+			UserID:    userID,
+			Payload:   modifiedJSON,
+			CreatedAt: scannedAt,
+			Type:      string(s.cfg.MessageType),
+			Source:    string(platformType),
+		}
+		if err := s.send(ctx, event); err != nil {
+			logging.Errorw(ctx, "Failed to send ocr result", withCorrelationID(ctx,
+				"error", err,
+				"user_id", userID,
+				"topic", s.cfg.Topic,
+				"model", opts.Model,
+				"latency_ms", latency.Milliseconds(),
+			)...)
+			if s.cfg.OnSendError != nil {
+				s.cfg.OnSendError(ctx, event, err)
+			}
+			if s.cfg.ReturnSendError {
+				return nil, fmt.Errorf("failed to send ocr result: %w", err)
+			}
+		}
 	}
 
 	return &ocr, nil
 }
+
+// send publishes event to the configured topic, retrying up to
+// Config.SendRetries times with exponential backoff.
+func (s *ocrStore) send(ctx context.Context, event models.OCREventMessage) error {
+	backoff := s.cfg.SendRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.mq.SendWithContext(ctx, string(s.cfg.Topic), event)
+		if err == nil || attempt >= s.cfg.SendRetries {
+			return err
+		}
+
+		logging.Warn(ctx, "Failed to send ocr result, retrying in %s: %v", backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// splitDocuments separates PDF links, which are sent as documents, from
+// image links.
+func splitDocuments(links []string) (imageUrls []string, documentUrls []string) {
+	for _, link := range links {
+		if isPDF(link) {
+			documentUrls = append(documentUrls, link)
+		} else {
+			imageUrls = append(imageUrls, link)
+		}
+	}
+	return imageUrls, documentUrls
+}
+
+// isPDF reports whether link's path ends in .pdf, ignoring any query string.
+func isPDF(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(path.Ext(u.Path), ".pdf")
+}
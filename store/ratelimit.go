@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+
+	"github.com/A-pen-app/ai-client/models"
+	"golang.org/x/time/rate"
+)
+
+type rateLimitedClient struct {
+	inner   AIClient
+	limiter *rate.Limiter
+}
+
+// NewRateLimitedClient wraps an AIClient so that calls block until the
+// token bucket allows them, at rps requests per second with the given burst.
+//
+// Each NewRateLimitedClient call has its own budget: two wrappers around the
+// same provider key do not share a limit, and together they may send twice
+// the rate. Reuse one wrapper to enforce a combined limit.
+func NewRateLimitedClient(inner AIClient, rps float64, burst int) AIClient {
+	return &rateLimitedClient{
+		inner:   inner,
+		limiter: rate.NewLimiter(rate.Limit(rps), burst),
+	}
+}
+
+func (c *rateLimitedClient) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	if err := c.wait(ctx); err != nil {
+		return "", err
+	}
+	return c.inner.Generate(ctx, message, opts)
+}
+
+func (c *rateLimitedClient) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+func (c *rateLimitedClient) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	if err := c.wait(ctx); err != nil {
+		return "", models.Usage{}, err
+	}
+	return c.inner.GenerateWithUsage(ctx, message, opts)
+}
+
+func (c *rateLimitedClient) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	if err := c.wait(ctx); err != nil {
+		return models.GenerateResult{}, err
+	}
+	return c.inner.GenerateWithTools(ctx, message, opts)
+}
+
+func (c *rateLimitedClient) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Texts(), nil
+}
+
+func (c *rateLimitedClient) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.inner.StreamGenerate(ctx, message, opts)
+}
+
+// HealthCheck checks the wrapped client.
+func (c *rateLimitedClient) HealthCheck(ctx context.Context) error {
+	return c.inner.HealthCheck(ctx)
+}
+
+// Close closes the wrapped client.
+func (c *rateLimitedClient) Close() error {
+	return c.inner.Close()
+}
+
+// wait blocks until a token is available, returning ctx.Err() if the
+// context is cancelled first.
+func (c *rateLimitedClient) wait(ctx context.Context) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package store
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/util"
+)
+
+// RetryConfig controls how NewRetryingClient retries failed calls. Zero
+// values fall back to the defaults noted on each field.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, including the first (default: 3).
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry (default: 500ms).
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts (default: 10s).
+	MaxBackoff time.Duration
+	// MaxElapsedTime stops retrying once the next attempt would start after
+	// this much time has passed since the first one (default: 30s).
+	MaxElapsedTime time.Duration
+	// IsRetryable decides whether an error should be retried (default: IsRetryableError).
+	IsRetryable func(error) bool
+	// EmptyResponseRetries is how many times a call that succeeded but
+	// returned no content (models.ErrorKindEmptyResponse, or only empty
+	// text) is retried, on top of MaxAttempts (default: 2). Empty text still
+	// empty after the last retry fails with ErrorKindEmptyResponse. Set it to
+	// a negative value to disable; empty text is then returned as is.
+	// Content-filter blocks are reported as ErrorKindContentFiltered and are
+	// never retried.
+	EmptyResponseRetries int
+	// RetryInvalidJSON retries a call once more when it asks for JSON with
+	// models.ResponseFormatJSON or ResponseFormatJSONSchema but the response
+	// does not parse even after util.RepairJSON, with JSONRetryInstruction
+	// appended to the system prompt. If that attempt is not valid JSON
+	// either, its response is returned as is. Tool calls and streams are
+	// not checked.
+	RetryInvalidJSON bool
+}
+
+// JSONRetryInstruction is appended to the system prompt when
+// RetryConfig.RetryInvalidJSON retries a response that was not valid JSON.
+const JSONRetryInstruction = "Return ONLY valid JSON, no prose."
+
+type retryingClient struct {
+	inner AIClient
+	cfg   RetryConfig
+}
+
+// NewRetryingClient wraps an AIClient so that transient provider failures
+// and empty responses are retried with exponential backoff and jitter.
+func NewRetryingClient(inner AIClient, cfg RetryConfig) AIClient {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = 500 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 10 * time.Second
+	}
+	if cfg.MaxElapsedTime <= 0 {
+		cfg.MaxElapsedTime = 30 * time.Second
+	}
+	if cfg.IsRetryable == nil {
+		cfg.IsRetryable = IsRetryableError
+	}
+	if cfg.EmptyResponseRetries == 0 {
+		cfg.EmptyResponseRetries = 2
+	}
+
+	return &retryingClient{
+		inner: inner,
+		cfg:   cfg,
+	}
+}
+
+// IsRetryableError reports whether err looks transient: rate limiting,
+// provider-side 5xx failures, request timeouts (errors matching
+// models.ErrRequestTimeout), and network timeouts. Other context errors and
+// 4xx errors are never retried, and neither are failed image or media
+// downloads, which util retries on its own (see util.DownloadRetries). The
+// retrying client also stops once the caller's own context is done.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	// A request timeout also wraps context.DeadlineExceeded, but only the
+	// attempt ran out of time, not the caller.
+	if errors.Is(err, models.ErrRequestTimeout) {
+		return true
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var downloadErr *util.DownloadError
+	if errors.As(err, &downloadErr) {
+		return false
+	}
+
+	var aiErr *models.AIError
+	if errors.As(err, &aiErr) {
+		switch aiErr.Kind {
+		case models.ErrorKindRateLimited, models.ErrorKindTimeout:
+			return true
+		case models.ErrorKindServerError:
+			return aiErr.StatusCode != http.StatusNotImplemented
+		}
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return netErr.Timeout()
+	}
+
+	return false
+}
+
+func (c *retryingClient) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	var text string
+	err := c.call(ctx, message, opts, func(message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+		var err error
+		text, err = c.inner.Generate(ctx, message, opts)
+		return []string{text}, err
+	})
+	return text, err
+}
+
+func (c *retryingClient) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+func (c *retryingClient) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	var text string
+	var usage models.Usage
+	err := c.call(ctx, message, opts, func(message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+		var err error
+		text, usage, err = c.inner.GenerateWithUsage(ctx, message, opts)
+		return []string{text}, err
+	})
+	return text, usage, err
+}
+
+func (c *retryingClient) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	var result models.GenerateResult
+	err := c.call(ctx, message, opts, func(message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+		var err error
+		result, err = c.inner.GenerateWithTools(ctx, message, opts)
+		if len(result.ToolCalls) > 0 {
+			return nil, err
+		}
+		return result.Texts(), err
+	})
+	return result, err
+}
+
+func (c *retryingClient) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	result, err := c.GenerateWithTools(ctx, message, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Texts(), nil
+}
+
+// StreamGenerate retries establishing the stream. Errors that surface once
+// chunks have started flowing are delivered on the channel as usual.
+func (c *retryingClient) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	var stream <-chan models.StreamChunk
+	opts = withIdempotencyKey(opts)
+	err := c.do(ctx, func() error {
+		var err error
+		stream, err = c.inner.StreamGenerate(ctx, message, opts)
+		return err
+	})
+	return stream, err
+}
+
+// HealthCheck checks the wrapped client.
+func (c *retryingClient) HealthCheck(ctx context.Context) error {
+	return c.inner.HealthCheck(ctx)
+}
+
+// Close closes the wrapped client.
+func (c *retryingClient) Close() error {
+	return c.inner.Close()
+}
+
+// withIdempotencyKey gives every attempt of a logical request the same
+// idempotency key, so a provider that supports keys processes it once.
+func withIdempotencyKey(opts models.AIClientOptions) models.AIClientOptions {
+	if opts.IdempotencyKey == "" {
+		opts.IdempotencyKey = crand.Text()
+	}
+	return opts
+}
+
+// call runs fn with retries, counting only empty text as an empty response,
+// and once more with JSONRetryInstruction when RetryInvalidJSON is set and a
+// text fn returned is not valid JSON. The extra attempt gets its own
+// idempotency key, as its request differs.
+func (c *retryingClient) call(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions, fn func(models.AIChatMessage, models.AIClientOptions) ([]string, error)) error {
+	opts = withIdempotencyKey(opts)
+	var texts []string
+	err := c.do(ctx, func() error {
+		var err error
+		texts, err = fn(message, opts)
+		if err == nil && c.cfg.EmptyResponseRetries > 0 && emptyTexts(texts) {
+			err = &models.AIError{Kind: models.ErrorKindEmptyResponse, Err: errors.New("empty response content from AI client")}
+		}
+		return err
+	})
+	if err != nil || !c.cfg.RetryInvalidJSON || !expectsJSON(opts) || validJSON(texts) {
+		return err
+	}
+
+	message.SystemPrompt = strings.TrimSpace(message.SystemPrompt + "\n\n" + JSONRetryInstruction)
+	opts.IdempotencyKey = crand.Text()
+	return c.do(ctx, func() error {
+		_, err := fn(message, opts)
+		return err
+	})
+}
+
+// emptyTexts reports whether fn returned texts and all of them are empty.
+// Tool-call results return no texts and are never empty.
+func emptyTexts(texts []string) bool {
+	for _, text := range texts {
+		if text != "" {
+			return false
+		}
+	}
+	return len(texts) > 0
+}
+
+// expectsJSON reports whether opts asks for a JSON response.
+func expectsJSON(opts models.AIClientOptions) bool {
+	return opts.ResponseFormat == models.ResponseFormatJSON || opts.ResponseFormat == models.ResponseFormatJSONSchema
+}
+
+// validJSON reports whether every text parses as JSON after repair.
+func validJSON(texts []string) bool {
+	for _, text := range texts {
+		if !json.Valid([]byte(util.RepairJSON(text))) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *retryingClient) do(ctx context.Context, fn func() error) error {
+	start := time.Now()
+	backoff := c.cfg.InitialBackoff
+	emptyRetries := 0
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if models.KindOf(err) == models.ErrorKindEmptyResponse && emptyRetries < c.cfg.EmptyResponseRetries {
+			// Empty responses have their own budget and do not use up
+			// MaxAttempts.
+			emptyRetries++
+			attempt--
+		} else if attempt >= c.cfg.MaxAttempts || !c.cfg.IsRetryable(err) {
+			return err
+		}
+
+		// Equal jitter: wait between half and the full backoff.
+		delay := backoff/2 + rand.N(backoff/2+1)
+		if time.Since(start)+delay > c.cfg.MaxElapsedTime {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, c.cfg.MaxBackoff)
+	}
+}
@@ -0,0 +1,182 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/A-pen-app/ai-client/client/clienttest"
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+	"github.com/A-pen-app/ai-client/util"
+)
+
+// fastRetries keeps the backoff short so tests do not sleep.
+var fastRetries = store.RetryConfig{
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     time.Millisecond,
+}
+
+func emptyResponseError() error {
+	return &models.AIError{Kind: models.ErrorKindEmptyResponse, Provider: "gemini", Err: errors.New("empty response from Gemini")}
+}
+
+func TestRetryingClientRetriesEmptyText(t *testing.T) {
+	mock := clienttest.NewMockClient()
+	mock.EnqueueText("")
+	mock.EnqueueText("ok")
+	client := store.NewRetryingClient(mock, fastRetries)
+
+	text, err := client.Generate(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if text != "ok" {
+		t.Errorf("Generate = %q, want %q", text, "ok")
+	}
+	mock.AssertCallCount(t, 2)
+}
+
+func TestRetryingClientRetriesEmptyResponseError(t *testing.T) {
+	mock := clienttest.NewMockClient()
+	mock.EnqueueError(emptyResponseError())
+	mock.EnqueueText("ok")
+	client := store.NewRetryingClient(mock, fastRetries)
+
+	text, err := client.Generate(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if text != "ok" {
+		t.Errorf("Generate = %q, want %q", text, "ok")
+	}
+	mock.AssertCallCount(t, 2)
+}
+
+func TestRetryingClientEmptyResponseBudgetExhausted(t *testing.T) {
+	tests := []struct {
+		name    string
+		enqueue func(*clienttest.MockClient)
+	}{
+		{name: "empty text", enqueue: func(m *clienttest.MockClient) { m.EnqueueText("") }},
+		{name: "empty response error", enqueue: func(m *clienttest.MockClient) { m.EnqueueError(emptyResponseError()) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := clienttest.NewMockClient()
+			for range 5 {
+				tt.enqueue(mock)
+			}
+			cfg := fastRetries
+			cfg.MaxAttempts = 1
+			cfg.EmptyResponseRetries = 2
+			client := store.NewRetryingClient(mock, cfg)
+
+			_, err := client.Generate(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{})
+			if models.KindOf(err) != models.ErrorKindEmptyResponse {
+				t.Errorf("Generate error = %v, want an empty response error", err)
+			}
+			// The first attempt plus two empty-response retries.
+			mock.AssertCallCount(t, 3)
+		})
+	}
+}
+
+func TestRetryingClientEmptyResponseRetriesDisabled(t *testing.T) {
+	mock := clienttest.NewMockClient()
+	mock.EnqueueText("")
+	mock.EnqueueText("ok")
+	cfg := fastRetries
+	cfg.EmptyResponseRetries = -1
+	client := store.NewRetryingClient(mock, cfg)
+
+	text, err := client.Generate(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if text != "" {
+		t.Errorf("Generate = %q, want the empty text", text)
+	}
+	mock.AssertCallCount(t, 1)
+}
+
+func TestRetryingClientDoesNotRetryContentFiltered(t *testing.T) {
+	mock := clienttest.NewMockClient()
+	mock.EnqueueError(models.NewContentFilteredError("gemini", "SAFETY"))
+	mock.EnqueueText("ok")
+	client := store.NewRetryingClient(mock, fastRetries)
+
+	_, err := client.Generate(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{})
+	if models.KindOf(err) != models.ErrorKindContentFiltered {
+		t.Errorf("Generate error = %v, want a content-filtered error", err)
+	}
+	mock.AssertCallCount(t, 1)
+}
+
+// requestTimeoutError returns the error a client returns when
+// AIClientOptions.RequestTimeout expires.
+func requestTimeoutError() error {
+	ctx, cancel := util.WithRequestTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	return util.TimeoutError(ctx, fmt.Errorf("failed to generate content: %w", ctx.Err()))
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "request timeout", err: requestTimeoutError(), want: true},
+		{name: "rate limited", err: &models.AIError{Kind: models.ErrorKindRateLimited, StatusCode: 429, Err: errors.New("rate limit")}, want: true},
+		{name: "server error", err: &models.AIError{Kind: models.ErrorKindServerError, StatusCode: 503, Err: errors.New("unavailable")}, want: true},
+		{name: "not implemented", err: &models.AIError{Kind: models.ErrorKindServerError, StatusCode: 501, Err: errors.New("not implemented")}, want: false},
+		{name: "invalid request", err: &models.AIError{Kind: models.ErrorKindInvalidRequest, StatusCode: 400, Err: errors.New("bad request")}, want: false},
+		{name: "caller cancelled", err: fmt.Errorf("failed: %w", context.Canceled), want: false},
+		{name: "caller deadline", err: fmt.Errorf("failed: %w", context.DeadlineExceeded), want: false},
+		{name: "download", err: &util.DownloadError{StatusCode: 503, Transient: true, Err: errors.New("unavailable")}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := store.IsRetryableError(tt.err); got != tt.want {
+				t.Errorf("IsRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryingClientRetriesRequestTimeout(t *testing.T) {
+	mock := clienttest.NewMockClient()
+	mock.EnqueueError(requestTimeoutError())
+	mock.EnqueueText("ok")
+	client := store.NewRetryingClient(mock, fastRetries)
+
+	text, err := client.Generate(context.Background(), models.AIChatMessage{Text: "hi"}, models.AIClientOptions{RequestTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if text != "ok" {
+		t.Errorf("Generate = %q, want %q", text, "ok")
+	}
+	mock.AssertCallCount(t, 2)
+}
+
+func TestRetryingClientStopsWhenCallerContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mock := clienttest.NewMockClient()
+	mock.EnqueueText("ok")
+	client := store.NewRetryingClient(mock, fastRetries)
+
+	// The mock fails with the caller's context error, which is final.
+	if _, err := client.Generate(ctx, models.AIChatMessage{Text: "hi"}, models.AIClientOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Generate error = %v, want context.Canceled", err)
+	}
+	mock.AssertCallCount(t, 1)
+}
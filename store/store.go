@@ -7,15 +7,53 @@ import (
 )
 
 type OCR interface {
-	ScanName(ctx context.Context, link string) (string, error)
-	ScanRawInfo(ctx context.Context, userID string, link string, professionType models.PlatformType) (*models.OCRRawInfo, error)
+	ScanName(ctx context.Context, link string, opts ...CallOption) (string, error)
+	ScanNames(ctx context.Context, links []string, opts ...CallOption) ([]models.ScanNameResult, error)
+	ScanField(ctx context.Context, link string, field string, professionType models.PlatformType, opts ...CallOption) (string, error)
+	ScanRawInfo(ctx context.Context, userID string, link string, professionType models.PlatformType, opts ...CallOption) (*models.OCRRawInfo, error)
+	ScanRawInfoMulti(ctx context.Context, userID string, links []string, professionType models.PlatformType, opts ...CallOption) (*models.OCRRawInfo, error)
 }
 
 type Article interface {
-	ExtractTags(ctx context.Context, content string, professionType models.PlatformType) (*models.ExtractTagsResult, error)
-	Polish(ctx context.Context, content string, professionType models.PlatformType) (string, error)
+	ExtractTags(ctx context.Context, content string, professionType models.PlatformType, opts ...CallOption) (*models.ExtractTagsResult, error)
+	ExtractEntities(ctx context.Context, content string, professionType models.PlatformType, opts ...CallOption) ([]models.Entity, error)
+	Polish(ctx context.Context, content string, professionType models.PlatformType, opts ...CallOption) (string, error)
+	PolishWithTruncation(ctx context.Context, content string, professionType models.PlatformType, opts ...CallOption) (string, bool, error)
+	PolishWithDiff(ctx context.Context, content string, professionType models.PlatformType, opts ...CallOption) (models.PolishResult, error)
+	Translate(ctx context.Context, content string, targetLang string, professionType models.PlatformType, opts ...CallOption) (string, error)
+	GenerateTitle(ctx context.Context, content string, professionType models.PlatformType, maxChars int, opts ...CallOption) (string, error)
+	Moderate(ctx context.Context, content string) (models.ModerationResult, error)
 }
 
 type AIClient interface {
 	Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error)
+	// GenerateChat replies to an ordered conversation of system, user and
+	// assistant turns. It is Generate with the turns mapped onto a message
+	// by models.NewChatMessage.
+	GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error)
+	GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error)
+	GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error)
+	GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error)
+	StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error)
+	// HealthCheck makes a minimal request to verify connectivity,
+	// credentials and the default model. Invalid credentials return an
+	// error of kind models.ErrorKindAuth.
+	HealthCheck(ctx context.Context) error
+	// Close releases the client's resources. Calls made after Close return
+	// an error matching models.ErrClientClosed.
+	Close() error
+}
+
+// RequestBuilder is implemented by provider clients that can return the
+// provider-specific request they would send for a message without sending
+// it, e.g. to snapshot-test prompt assembly or dump the exact payload.
+// Type-assert an AIClient to check for support.
+type RequestBuilder interface {
+	BuildRequest(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (any, error)
+}
+
+// Transcriber is implemented by provider clients that can turn an audio
+// clip into text. Type-assert an AIClient to check for support.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio models.AudioData, opts models.AIClientOptions) (string, error)
 }
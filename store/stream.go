@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// StreamTo streams client's response to message into w, writing each chunk
+// as it arrives and flushing after each write when w is an http.Flusher,
+// such as an http.ResponseWriter serving server-sent events. It returns
+// when the stream is done, with the provider error if the stream failed,
+// ctx's error if ctx was canceled, or the error of a failed write, which
+// stops the stream.
+func StreamTo(ctx context.Context, client AIClient, message models.AIChatMessage, opts models.AIClientOptions, w io.Writer) error {
+	// Canceling stops the provider when a write fails.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.StreamGenerate(ctx, message, opts)
+	if err != nil {
+		return err
+	}
+
+	flusher, _ := w.(http.Flusher)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case chunk, ok := <-stream:
+			if !ok {
+				return nil
+			}
+			if chunk.Text != "" {
+				if _, err := io.WriteString(w, chunk.Text); err != nil {
+					return fmt.Errorf("failed to write stream chunk: %w", err)
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			if chunk.Done {
+				return chunk.Err
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package tracing adds OpenTelemetry spans to an AIClient. It lives in its
+// own package so that importing store does not pull in OpenTelemetry.
+package tracing
+
+import (
+	"context"
+
+	"github.com/A-pen-app/ai-client/models"
+	"github.com/A-pen-app/ai-client/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracedClient struct {
+	inner    store.AIClient
+	tracer   trace.Tracer
+	provider string
+}
+
+// NewTracedClient wraps an AIClient so that every call runs in a span
+// carrying the provider, model, prompt length, image count, response length
+// and token usage. Errors are recorded on the span and mark it as failed.
+func NewTracedClient(inner store.AIClient, tracer trace.Tracer, provider string) store.AIClient {
+	return &tracedClient{
+		inner:    inner,
+		tracer:   tracer,
+		provider: provider,
+	}
+}
+
+func (c *tracedClient) Generate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, error) {
+	ctx, span := c.start(ctx, "AIClient.Generate", message, opts)
+	defer span.End()
+
+	text, err := c.inner.Generate(ctx, message, opts)
+	span.SetAttributes(attribute.Int("ai.response.length", len(text)))
+	recordError(span, err)
+	return text, err
+}
+
+// GenerateChat is recorded as a Generate call.
+func (c *tracedClient) GenerateChat(ctx context.Context, turns []models.ChatTurn, opts models.AIClientOptions) (string, error) {
+	message, err := models.NewChatMessage(turns)
+	if err != nil {
+		return "", err
+	}
+	return c.Generate(ctx, message, opts)
+}
+
+func (c *tracedClient) GenerateWithUsage(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (string, models.Usage, error) {
+	ctx, span := c.start(ctx, "AIClient.GenerateWithUsage", message, opts)
+	defer span.End()
+
+	text, usage, err := c.inner.GenerateWithUsage(ctx, message, opts)
+	span.SetAttributes(attribute.Int("ai.response.length", len(text)))
+	setUsage(span, usage)
+	recordError(span, err)
+	return text, usage, err
+}
+
+func (c *tracedClient) GenerateWithTools(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (models.GenerateResult, error) {
+	ctx, span := c.start(ctx, "AIClient.GenerateWithTools", message, opts)
+	defer span.End()
+
+	result, err := c.inner.GenerateWithTools(ctx, message, opts)
+	span.SetAttributes(
+		attribute.Int("ai.response.length", len(result.Text)),
+		attribute.Int("ai.response.tool_calls", len(result.ToolCalls)),
+	)
+	setUsage(span, result.Usage)
+	recordError(span, err)
+	return result, err
+}
+
+func (c *tracedClient) GenerateN(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) ([]string, error) {
+	ctx, span := c.start(ctx, "AIClient.GenerateN", message, opts)
+	defer span.End()
+
+	texts, err := c.inner.GenerateN(ctx, message, opts)
+	span.SetAttributes(attribute.Int("ai.response.candidates", len(texts)))
+	recordError(span, err)
+	return texts, err
+}
+
+// StreamGenerate traces establishing the stream only; the span ends once
+// the stream has been returned.
+func (c *tracedClient) StreamGenerate(ctx context.Context, message models.AIChatMessage, opts models.AIClientOptions) (<-chan models.StreamChunk, error) {
+	ctx, span := c.start(ctx, "AIClient.StreamGenerate", message, opts)
+	defer span.End()
+
+	stream, err := c.inner.StreamGenerate(ctx, message, opts)
+	recordError(span, err)
+	return stream, err
+}
+
+func (c *tracedClient) HealthCheck(ctx context.Context) error {
+	ctx, span := c.tracer.Start(ctx, "AIClient.HealthCheck",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("ai.provider", c.provider)),
+	)
+	defer span.End()
+
+	err := c.inner.HealthCheck(ctx)
+	recordError(span, err)
+	return err
+}
+
+// Close closes the wrapped client.
+func (c *tracedClient) Close() error {
+	return c.inner.Close()
+}
+
+func (c *tracedClient) start(ctx context.Context, name string, message models.AIChatMessage, opts models.AIClientOptions) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{
+		attribute.String("ai.provider", c.provider),
+		attribute.String("ai.model", opts.Model),
+		attribute.Int("ai.prompt.length", len(message.SystemPrompt)+len(message.Text)),
+		attribute.Int("ai.image.count", len(message.ImageUrls)+len(message.Images)),
+		attribute.Int("ai.audio.count", len(message.AudioUrls)+len(message.Audio)),
+		attribute.Int("ai.document.count", len(message.DocumentUrls)),
+	}
+	if id := models.CorrelationID(ctx); id != "" {
+		attributes = append(attributes, attribute.String("ai.correlation_id", id))
+	}
+
+	return c.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...),
+	)
+}
+
+func setUsage(span trace.Span, usage models.Usage) {
+	if usage == (models.Usage{}) {
+		return
+	}
+	span.SetAttributes(
+		attribute.Int64("ai.usage.prompt_tokens", usage.PromptTokens),
+		attribute.Int64("ai.usage.completion_tokens", usage.CompletionTokens),
+		attribute.Int64("ai.usage.total_tokens", usage.TotalTokens),
+		attribute.Int64("ai.usage.cached_prompt_tokens", usage.CachedPromptTokens),
+	)
+}
+
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
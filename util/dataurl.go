@@ -0,0 +1,68 @@
+package util
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// IsDataURL reports whether link is a data: URL, such as
+// "data:image/png;base64,iVBORw0...".
+func IsDataURL(link string) bool {
+	return len(link) >= 5 && strings.EqualFold(link[:5], "data:")
+}
+
+// DecodeDataURL returns the bytes and MIME type of a data: URL, decoding a
+// base64 or percent-encoded payload. A base64 payload may itself be
+// percent-encoded. The MIME type is empty when the URL does not declare one.
+func DecodeDataURL(link string) ([]byte, string, error) {
+	if !IsDataURL(link) {
+		return nil, "", fmt.Errorf("not a data URL")
+	}
+
+	header, payload, ok := strings.Cut(link[5:], ",")
+	if !ok {
+		return nil, "", fmt.Errorf("invalid data URL: missing comma")
+	}
+
+	isBase64 := false
+	if rest, found := strings.CutSuffix(header, ";base64"); found {
+		header, isBase64 = rest, true
+	}
+
+	var mimeType string
+	if header != "" {
+		mediaType, _, err := mime.ParseMediaType(header)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid data URL media type: %w", err)
+		}
+		mimeType = mediaType
+	}
+
+	if !isBase64 {
+		data, err := url.PathUnescape(payload)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid data URL payload: %w", err)
+		}
+		return []byte(data), mimeType, nil
+	}
+
+	if strings.Contains(payload, "%") {
+		unescaped, err := url.PathUnescape(payload)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid data URL payload: %w", err)
+		}
+		payload = unescaped
+	}
+
+	// Tolerate the URL-safe alphabet and missing padding some encoders use.
+	payload = strings.TrimRight(payload, "=")
+	payload = strings.NewReplacer("-", "+", "_", "/").Replace(payload)
+	data, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid data URL payload: %w", err)
+	}
+	return data, mimeType, nil
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// onePixelPNG is a 1x1 PNG whose base64 encoding has "+", "/" and padding,
+// so that every encoding variant differs from the standard one.
+const onePixelPNG = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAIAAACQd1PeAAAAEUlEQVR4nAAEAPv/AgUAAAMAABsACA4+KkgAAAAASUVORK5CYII="
+
+func onePixelPNGBytes(t *testing.T) []byte {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(onePixelPNG)
+	if err != nil {
+		t.Fatalf("decoding fixture: %v", err)
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width != 1 || config.Height != 1 {
+		t.Fatalf("fixture is not a 1x1 PNG: %+v, %v", config, err)
+	}
+	return data
+}
+
+func TestDecodeDataURL(t *testing.T) {
+	want := onePixelPNGBytes(t)
+	urlSafe := strings.NewReplacer("+", "-", "/", "_").Replace(onePixelPNG)
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "standard", url: "data:image/png;base64," + onePixelPNG},
+		{name: "unpadded", url: "data:image/png;base64," + strings.TrimRight(onePixelPNG, "=")},
+		{name: "URL-safe", url: "data:image/png;base64," + urlSafe},
+		{name: "URL-safe unpadded", url: "data:image/png;base64," + strings.TrimRight(urlSafe, "=")},
+		{name: "percent-encoded", url: "data:image/png;base64," + strings.NewReplacer("+", "%2B", "/", "%2F", "=", "%3D").Replace(onePixelPNG)},
+		{name: "upper-case scheme", url: "DATA:image/png;base64," + onePixelPNG},
+		{name: "media type parameters", url: "data:image/png;name=card.png;base64," + onePixelPNG},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, mimeType, err := DecodeDataURL(tt.url)
+			if err != nil {
+				t.Fatalf("DecodeDataURL: %v", err)
+			}
+			if !bytes.Equal(data, want) {
+				t.Errorf("DecodeDataURL bytes = %x, want %x", data, want)
+			}
+			if mimeType != "image/png" {
+				t.Errorf("DecodeDataURL MIME type = %q, want %q", mimeType, "image/png")
+			}
+		})
+	}
+}
+
+func TestDecodeDataURLText(t *testing.T) {
+	data, mimeType, err := DecodeDataURL("data:,Hello%2C%20World")
+	if err != nil {
+		t.Fatalf("DecodeDataURL: %v", err)
+	}
+	if string(data) != "Hello, World" {
+		t.Errorf("DecodeDataURL = %q, want %q", data, "Hello, World")
+	}
+	if mimeType != "" {
+		t.Errorf("DecodeDataURL MIME type = %q, want none", mimeType)
+	}
+}
+
+func TestDecodeDataURLInvalid(t *testing.T) {
+	for _, link := range []string{
+		"https://example.com/card.png",
+		"data:image/png;base64",
+		"data:image/png;base64,!!!",
+		"data:image/png;base64,%ZZ",
+	} {
+		if _, _, err := DecodeDataURL(link); err == nil {
+			t.Errorf("DecodeDataURL(%q) succeeded, want an error", link)
+		}
+	}
+}
+
+func TestLoadImagesDataURL(t *testing.T) {
+	want := onePixelPNGBytes(t)
+	message := models.AIChatMessage{ImageUrls: []string{
+		"data:image/png;base64," + onePixelPNG,
+		"data:image/png;base64," + strings.TrimRight(strings.NewReplacer("+", "-", "/", "_").Replace(onePixelPNG), "="),
+	}}
+
+	images, err := LoadImagesWith(context.Background(), message, nil)
+	if err != nil {
+		t.Fatalf("LoadImagesWith: %v", err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("LoadImagesWith returned %d images, want 2", len(images))
+	}
+	for i, image := range images {
+		if !bytes.Equal(image.Bytes, want) {
+			t.Errorf("image %d bytes = %x, want %x", i, image.Bytes, want)
+		}
+		if image.MimeType != "image/png" {
+			t.Errorf("image %d MIME type = %q, want %q", i, image.MimeType, "image/png")
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"slices"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// ErrHEICUnsupported is returned, wrapped, by ConvertHEIC when the binary was
+// built without HEIC decoding. Build with -tags heic, which needs cgo, to
+// include it.
+var ErrHEICUnsupported = errors.New("HEIC decoding is not compiled in; build with -tags heic")
+
+// decodeHEIC decodes a HEIC image. It is set by heic_decoder.go, which is
+// only built with the heic tag.
+var decodeHEIC func(io.Reader) (image.Image, error)
+
+// heicBrands are the ISO BMFF brands of HEVC-coded HEIF images, as written
+// by iPhones and most Android phones.
+var heicBrands = []string{"heic", "heix", "heim", "heis", "hevc", "hevx", "hevm", "hevs"}
+
+// IsHEIC reports whether data is a HEIC image, judging by the brands in its
+// ftyp box.
+func IsHEIC(data []byte) bool {
+	if len(data) < 12 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+
+	major := string(data[8:12])
+	if slices.Contains(heicBrands, major) {
+		return true
+	}
+	if major != "mif1" && major != "msf1" {
+		return false
+	}
+
+	// Generic HEIF files list the codec among the compatible brands, which
+	// follow the major brand and minor version.
+	size := int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+	for i := 16; i+4 <= min(size, len(data)); i += 4 {
+		if slices.Contains(heicBrands, string(data[i:i+4])) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConvertHEIC decodes a HEIC image and re-encodes it as JPEG at jpegQuality
+// (1-100, default DefaultJPEGQuality). Without the heic build tag it fails
+// with ErrHEICUnsupported.
+func ConvertHEIC(data []byte, jpegQuality int) ([]byte, error) {
+	if decodeHEIC == nil {
+		return nil, ErrHEICUnsupported
+	}
+
+	img, err := decodeHEIC(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode HEIC image: %w", err)
+	}
+
+	if jpegQuality < 1 || jpegQuality > 100 {
+		jpegQuality = DefaultJPEGQuality
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ConvertHEICImages converts the HEIC images among images to JPEG with
+// ConvertHEIC and leaves the others untouched.
+func ConvertHEICImages(images []models.ImageData, jpegQuality int) ([]models.ImageData, error) {
+	converted := slices.Clone(images)
+	for i, img := range images {
+		if !IsHEIC(img.Bytes) {
+			continue
+		}
+
+		data, err := ConvertHEIC(img.Bytes, jpegQuality)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert image %d: %w", i, err)
+		}
+		converted[i] = models.ImageData{Bytes: data, MimeType: "image/jpeg"}
+	}
+	return converted, nil
+}
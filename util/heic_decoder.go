@@ -0,0 +1,9 @@
+//go:build heic
+
+package util
+
+import "github.com/jdeng/goheif"
+
+func init() {
+	decodeHEIC = goheif.Decode
+}
@@ -0,0 +1,128 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	// Register the decoders PreprocessImage understands.
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// DefaultJPEGQuality is used by PreprocessImage when jpegQuality is not in
+// the range 1-100.
+const DefaultJPEGQuality = 85
+
+// PreprocessImage downscales a JPEG, PNG or GIF image so that neither side
+// exceeds maxDim pixels and re-encodes it as JPEG, returning the new bytes
+// and MIME type. Images that already fit, or a maxDim of 0 or less, are
+// returned unchanged with their detected MIME type. Transparent areas are
+// flattened onto white.
+func PreprocessImage(data []byte, maxDim int, jpegQuality int) ([]byte, string, error) {
+	resized, ok, err := resizeImage(data, maxDim, jpegQuality)
+	if err != nil {
+		return nil, "", err
+	}
+	if !ok {
+		return data, http.DetectContentType(data), nil
+	}
+	return resized, "image/jpeg", nil
+}
+
+// PreprocessImages applies PreprocessImage to each image. Images in a format
+// PreprocessImage cannot decode, such as WebP, are passed through unchanged
+// so that the provider can still reject or accept them.
+func PreprocessImages(images []models.ImageData, maxDim int, jpegQuality int) []models.ImageData {
+	if maxDim <= 0 {
+		return images
+	}
+
+	processed := make([]models.ImageData, len(images))
+	for i, img := range images {
+		processed[i] = img
+		if resized, ok, err := resizeImage(img.Bytes, maxDim, jpegQuality); err == nil && ok {
+			processed[i] = models.ImageData{Bytes: resized, MimeType: "image/jpeg"}
+		}
+	}
+	return processed
+}
+
+// resizeImage downscales and re-encodes data as JPEG, reporting false when
+// the image already fits within maxDim.
+func resizeImage(data []byte, maxDim int, jpegQuality int) ([]byte, bool, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if maxDim <= 0 || (config.Width <= maxDim && config.Height <= maxDim) {
+		return nil, false, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	width, height := fitWithin(config.Width, config.Height, maxDim)
+	dst := downscale(src, width, height)
+
+	if jpegQuality < 1 || jpegQuality > 100 {
+		jpegQuality = DefaultJPEGQuality
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, false, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), true, nil
+}
+
+// fitWithin scales width and height down, keeping the aspect ratio, so that
+// the longer side is maxDim.
+func fitWithin(width, height, maxDim int) (int, int) {
+	if width >= height {
+		return maxDim, max(height*maxDim/width, 1)
+	}
+	return max(width*maxDim/height, 1), maxDim
+}
+
+// downscale resizes src to width x height by averaging the source pixels
+// that fall into each destination pixel, compositing over white.
+func downscale(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Over)
+
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcHeight/height, max((y+1)*srcHeight/height, y*srcHeight/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcWidth/width, max((x+1)*srcWidth/width, x*srcWidth/width+1)
+
+			var r, g, b, n int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += int(row[sx*4])
+					g += int(row[sx*4+1])
+					b += int(row[sx*4+2])
+					n++
+				}
+			}
+
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// ExtractJSON returns the JSON value embedded in a model response, removing
+// surrounding markdown code fences and any prose before or after it. Clean
+// JSON is returned unchanged apart from surrounding whitespace.
+func ExtractJSON(raw string) string {
+	s := strings.TrimSpace(raw)
+	if json.Valid([]byte(s)) {
+		return s
+	}
+
+	if start := strings.Index(s, "```"); start >= 0 {
+		block := s[start+3:]
+		if end := strings.Index(block, "```"); end >= 0 {
+			block = block[:end]
+		}
+		// Drop the language tag on the opening fence line, e.g. "json".
+		if i := strings.IndexByte(block, '\n'); i >= 0 && !strings.ContainsAny(block[:i], "{[") {
+			block = block[i+1:]
+		}
+		s = strings.TrimSpace(block)
+	}
+
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return s
+	}
+	closer := byte('}')
+	if s[start] == '[' {
+		closer = ']'
+	}
+	if end := strings.LastIndexByte(s, closer); end > start {
+		return s[start : end+1]
+	}
+	return s[start:]
+}
+
+// RepairJSON extracts the JSON from a model response with ExtractJSON and
+// removes trailing commas before a closing brace or bracket. Well-formed
+// JSON is returned unchanged apart from surrounding whitespace.
+func RepairJSON(raw string) string {
+	return removeTrailingCommas(ExtractJSON(raw))
+}
+
+// UnmarshalJSON repairs raw with RepairJSON and decodes it into v. When the
+// text still is not valid JSON it returns a *models.AIError of kind
+// models.ErrorKindInvalidJSON carrying the raw text.
+func UnmarshalJSON(raw string, v any) error {
+	if err := json.Unmarshal([]byte(RepairJSON(raw)), v); err != nil {
+		return models.NewInvalidJSONError(raw, err)
+	}
+	return nil
+}
+
+// removeTrailingCommas drops commas that directly precede a closing brace
+// or bracket, ignoring commas inside strings.
+func removeTrailingCommas(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			sb.WriteByte(c)
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case ',':
+			j := i + 1
+			for j < len(s) && strings.IndexByte(" \t\r\n", s[j]) >= 0 {
+				j++
+			}
+			if j < len(s) && (s[j] == '}' || s[j] == ']') {
+				continue
+			}
+		}
+		sb.WriteByte(c)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,226 @@
+package util
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{
+			name: "clean object",
+			raw:  `{"name":"Alice","age":30}`,
+			want: `{"name":"Alice","age":30}`,
+		},
+		{
+			name: "clean array with surrounding whitespace",
+			raw:  "\n  [1, 2, 3]  \n",
+			want: `[1, 2, 3]`,
+		},
+		{
+			name: "json fence",
+			raw:  "```json\n{\"name\":\"Alice\"}\n```",
+			want: `{"name":"Alice"}`,
+		},
+		{
+			name: "fence without language tag",
+			raw:  "```\n{\"name\":\"Alice\"}\n```",
+			want: `{"name":"Alice"}`,
+		},
+		{
+			name: "fenced array",
+			raw:  "```json\n[{\"id\":1},{\"id\":2}]\n```",
+			want: `[{"id":1},{"id":2}]`,
+		},
+		{
+			name: "prose before and after fence",
+			raw:  "Here is the result:\n```json\n{\"name\":\"Alice\"}\n```\nLet me know if you need anything else.",
+			want: `{"name":"Alice"}`,
+		},
+		{
+			name: "prose before and after without fence",
+			raw:  "Sure! The extracted data is {\"name\":\"Alice\"} as requested.",
+			want: `{"name":"Alice"}`,
+		},
+		{
+			name: "braces inside strings",
+			raw:  "Result: {\"note\":\"use {curly} and [square] brackets\"} done",
+			want: `{"note":"use {curly} and [square] brackets"}`,
+		},
+		{
+			name: "no JSON",
+			raw:  "  I cannot read this image.  ",
+			want: "I cannot read this image.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractJSON(tt.raw); got != tt.want {
+				t.Errorf("ExtractJSON(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{
+			name: "valid JSON is unchanged",
+			raw:  `{"a": [1, 2], "b": {"c": "d"}}`,
+			want: `{"a": [1, 2], "b": {"c": "d"}}`,
+		},
+		{
+			name: "trailing comma before brace",
+			raw:  `{"a": 1, "b": 2,}`,
+			want: `{"a": 1, "b": 2}`,
+		},
+		{
+			name: "trailing comma before bracket",
+			raw:  `{"a": [1, 2, 3,]}`,
+			want: `{"a": [1, 2, 3]}`,
+		},
+		{
+			name: "trailing commas followed by whitespace",
+			raw:  "{\n  \"a\": [\n    1,\n  ],\n}",
+			want: "{\n  \"a\": [\n    1\n  ]\n}",
+		},
+		{
+			name: "fenced with trailing commas",
+			raw:  "```json\n{\"name\": \"Alice\", \"tags\": [\"x\", \"y\",],}\n```",
+			want: `{"name": "Alice", "tags": ["x", "y"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RepairJSON(tt.raw); got != tt.want {
+				t.Errorf("RepairJSON(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveTrailingCommas(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "no trailing commas",
+			in:   `{"a": 1, "b": [2, 3]}`,
+			want: `{"a": 1, "b": [2, 3]}`,
+		},
+		{
+			name: "nested trailing commas",
+			in:   `{"a": {"b": [1,],},}`,
+			want: `{"a": {"b": [1]}}`,
+		},
+		{
+			name: "comma and brace inside string",
+			in:   `{"a": "x, }", "b": "y,]"}`,
+			want: `{"a": "x, }", "b": "y,]"}`,
+		},
+		{
+			name: "escaped quotes inside string",
+			in:   `{"a": "say \"hi,\" }", "b": 1,}`,
+			want: `{"a": "say \"hi,\" }", "b": 1}`,
+		},
+		{
+			name: "escaped backslash before closing quote",
+			in:   `{"a": "C:\\", "b": [1,]}`,
+			want: `{"a": "C:\\", "b": [1]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := removeTrailingCommas(tt.in); got != tt.want {
+				t.Errorf("removeTrailingCommas(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// Fenced responses as returned by Gemini and OpenAI in JSON mode.
+var fencedResponses = []struct {
+	name string
+	raw  string
+}{
+	{
+		name: "gemini json fence",
+		raw:  "```json\n{\n  \"name\": \"王小明\",\n  \"license_number\": \"A123456789\",\n  \"department\": \"內科\"\n}\n```",
+	},
+	{
+		name: "openai fence with preamble",
+		raw:  "Here is the extracted information:\n\n```json\n{\"name\": \"王小明\", \"license_number\": \"A123456789\", \"department\": \"內科\"}\n```",
+	},
+	{
+		name: "fence with trailing note",
+		raw:  "```\n{\"name\": \"王小明\", \"license_number\": \"A123456789\", \"department\": \"內科\"}\n```\n\nNote: the department was partially obscured.",
+	},
+	{
+		name: "fence with trailing comma",
+		raw:  "```json\n{\n  \"name\": \"王小明\",\n  \"license_number\": \"A123456789\",\n  \"department\": \"內科\",\n}\n```",
+	},
+	{
+		name: "fence without newlines",
+		raw:  "```json{\"name\": \"王小明\", \"license_number\": \"A123456789\", \"department\": \"內科\"}```",
+	},
+}
+
+func TestUnmarshalJSONFencedResponses(t *testing.T) {
+	type license struct {
+		Name          string `json:"name"`
+		LicenseNumber string `json:"license_number"`
+		Department    string `json:"department"`
+	}
+	want := license{Name: "王小明", LicenseNumber: "A123456789", Department: "內科"}
+
+	for _, tt := range fencedResponses {
+		t.Run(tt.name, func(t *testing.T) {
+			var got license
+			if err := UnmarshalJSON(tt.raw, &got); err != nil {
+				t.Fatalf("UnmarshalJSON: %v", err)
+			}
+			if got != want {
+				t.Errorf("UnmarshalJSON = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONInvalid(t *testing.T) {
+	raws := []string{
+		"I'm sorry, I cannot read the text in this image.",
+		"```json\n{\"name\": \"王小明\", \"license_number\": }\n```",
+		`{"name": "王小明"`,
+	}
+
+	for _, raw := range raws {
+		var v map[string]any
+		err := UnmarshalJSON(raw, &v)
+
+		var aiErr *models.AIError
+		if !errors.As(err, &aiErr) {
+			t.Fatalf("UnmarshalJSON(%q) error = %v, want *models.AIError", raw, err)
+		}
+		if aiErr.Kind != models.ErrorKindInvalidJSON {
+			t.Errorf("UnmarshalJSON(%q) kind = %q, want %q", raw, aiErr.Kind, models.ErrorKindInvalidJSON)
+		}
+		if aiErr.RawResponse != raw {
+			t.Errorf("UnmarshalJSON(%q) RawResponse = %q, want the raw text", raw, aiErr.RawResponse)
+		}
+	}
+}
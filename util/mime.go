@@ -0,0 +1,78 @@
+package util
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/A-pen-app/logging"
+)
+
+// imageExtensions maps image file extensions to MIME types for formats
+// that the system MIME table may not list.
+var imageExtensions = map[string]string{
+	".heic": "image/heic",
+	".heif": "image/heif",
+	".webp": "image/webp",
+	".avif": "image/avif",
+}
+
+// DetectImageType sniffs the MIME type of a downloaded image. Sniffing does
+// not recognize some formats, such as HEIC photos from iPhones, and yields
+// application/octet-stream for them; providers reject that type. In that
+// case DetectImageType falls back to the server's contentType when it names
+// an image type, and then to the extension of link, logging a warning.
+func DetectImageType(ctx context.Context, data []byte, contentType string, link string) string {
+	detected := http.DetectContentType(data)
+	if detected != "application/octet-stream" {
+		return detected
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && strings.HasPrefix(mediaType, "image/") {
+		logging.Warn(ctx, "Could not detect image type of %s, using Content-Type %s", stripQuery(link), mediaType)
+		return mediaType
+	}
+
+	if mediaType := extensionType(link); mediaType != "" {
+		logging.Warn(ctx, "Could not detect image type of %s, using %s from its extension", stripQuery(link), mediaType)
+		return mediaType
+	}
+
+	logging.Warn(ctx, "Could not detect image type of %s, sending it as %s", stripQuery(link), detected)
+	return detected
+}
+
+// extensionType returns the image MIME type implied by the file extension
+// of link's path, or "" when there is none.
+func extensionType(link string) string {
+	p := link
+	if u, err := url.Parse(link); err == nil {
+		p = u.Path
+	}
+
+	ext := strings.ToLower(path.Ext(p))
+	if mediaType, ok := imageExtensions[ext]; ok {
+		return mediaType
+	}
+	if mediaType, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil && strings.HasPrefix(mediaType, "image/") {
+		return mediaType
+	}
+	return ""
+}
+
+// stripQuery drops the query and fragment of link before it is logged, as
+// they may hold a signature or token, and replaces a data URL entirely.
+func stripQuery(link string) string {
+	if IsDataURL(link) {
+		return "data URL"
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return "invalid URL"
+	}
+	u.RawQuery, u.Fragment = "", ""
+	return u.String()
+}
@@ -0,0 +1,58 @@
+package util
+
+import "github.com/A-pen-app/ai-client/models"
+
+const jsonInstruction = "Respond only with a single valid JSON object. Do not wrap it in code fences or add any other text."
+
+// WithJSONInstruction appends a JSON-only instruction to systemPrompt when
+// opts requests JSON output, for providers without a native JSON mode.
+// When a response schema is set the schema is included in the instruction.
+func WithJSONInstruction(systemPrompt string, opts models.AIClientOptions) string {
+	var instruction string
+	switch opts.ResponseFormat {
+	case models.ResponseFormatJSON:
+		instruction = jsonInstruction
+	case models.ResponseFormatJSONSchema:
+		instruction = jsonInstruction + " The object must conform to this JSON Schema:\n" + string(opts.ResponseSchema)
+	default:
+		return systemPrompt
+	}
+
+	if systemPrompt == "" {
+		return instruction
+	}
+	return systemPrompt + "\n\n" + instruction
+}
+
+// FoldSystemPrompt moves the system prompt into the first user message when
+// opts.SystemAsUser is set, for models that ignore the system role. The
+// first user message is the earliest user turn in History, or the message
+// text when History has none. The caller's History is not modified.
+func FoldSystemPrompt(message models.AIChatMessage, opts models.AIClientOptions) models.AIChatMessage {
+	if !opts.SystemAsUser || message.SystemPrompt == "" {
+		return message
+	}
+
+	systemPrompt := message.SystemPrompt
+	message.SystemPrompt = ""
+
+	for i, turn := range message.History {
+		if turn.Role != models.RoleUser {
+			continue
+		}
+		history := append([]models.Turn(nil), message.History...)
+		history[i].Text = prependPrompt(systemPrompt, turn.Text)
+		message.History = history
+		return message
+	}
+
+	message.Text = prependPrompt(systemPrompt, message.Text)
+	return message
+}
+
+func prependPrompt(systemPrompt string, text string) string {
+	if text == "" {
+		return systemPrompt
+	}
+	return systemPrompt + "\n\n" + text
+}
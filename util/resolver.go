@@ -0,0 +1,56 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ImageResolver fetches the image an entry of AIChatMessage.ImageUrls
+// refers to, e.g. to sign an object-store key or refresh an expired signed
+// URL before downloading it. It returns the image bytes and, when known,
+// their MIME type; an empty type is detected from the bytes.
+type ImageResolver interface {
+	Resolve(ctx context.Context, ref string) ([]byte, string, error)
+}
+
+// ImageResolverFunc adapts a function to an ImageResolver.
+type ImageResolverFunc func(ctx context.Context, ref string) ([]byte, string, error)
+
+// Resolve calls f.
+func (f ImageResolverFunc) Resolve(ctx context.Context, ref string) ([]byte, string, error) {
+	return f(ctx, ref)
+}
+
+// HTTPImageResolver downloads references as HTTP(S) or data: URLs, with
+// the timeout, retries and size limit of DownloadImage, and reports the
+// response's Content-Type or the type a data: URL declares. It is the
+// default resolver of every client.
+type HTTPImageResolver struct{}
+
+// Resolve downloads ref.
+func (HTTPImageResolver) Resolve(ctx context.Context, ref string) ([]byte, string, error) {
+	data, header, err := download(ctx, ref, "image", MaxImageBytes, ErrImageTooLarge)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, header.Get("Content-Type"), nil
+}
+
+// resolveImage runs resolver on ref, enforcing MaxImageBytes and reporting
+// failures as a *DownloadError, so that wrappers such as the retrying
+// client treat them like failed downloads.
+func resolveImage(ctx context.Context, resolver ImageResolver, ref string) ([]byte, string, error) {
+	data, mimeType, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		var downloadErr *DownloadError
+		if errors.As(err, &downloadErr) {
+			return nil, "", err
+		}
+		return nil, "", &DownloadError{Attempts: 1, Err: err}
+	}
+	if MaxImageBytes > 0 && int64(len(data)) > MaxImageBytes {
+		return nil, "", &DownloadError{Attempts: 1, Err: fmt.Errorf("%w: more than %d bytes", ErrImageTooLarge, MaxImageBytes)}
+	}
+	return data, mimeType, nil
+}
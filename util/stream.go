@@ -0,0 +1,37 @@
+package util
+
+import (
+	"context"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// SendChunk delivers chunk on ch, giving up if ctx is done first.
+// It reports whether the chunk was delivered.
+func SendChunk(ctx context.Context, ch chan<- models.StreamChunk, chunk models.StreamChunk) bool {
+	select {
+	case ch <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// FinishStream sends the terminal chunk for a stream. When ctx has been
+// cancelled the error chunk is delivered only if a reader is waiting, so
+// an abandoned stream never blocks the producing goroutine.
+func FinishStream(ctx context.Context, ch chan<- models.StreamChunk, err error) {
+	if err == nil {
+		err = ctx.Err()
+	}
+	err = TimeoutError(ctx, err)
+	chunk := models.StreamChunk{Done: true, Err: err}
+	if ctx.Err() != nil {
+		select {
+		case ch <- chunk:
+		default:
+		}
+		return
+	}
+	SendChunk(ctx, ch, chunk)
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// WithRequestTimeout derives a context that expires after timeout with
+// models.ErrRequestTimeout as its cause. A non-positive timeout only adds
+// cancellation. The returned cancel func must always be called.
+func WithRequestTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, models.ErrRequestTimeout)
+}
+
+// TimeoutError wraps err in a timeout *models.AIError matching
+// models.ErrRequestTimeout when ctx, created by WithRequestTimeout, expired
+// because of its own timeout rather than the caller's context.
+func TimeoutError(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, models.ErrRequestTimeout) {
+		return err
+	}
+	if errors.Is(context.Cause(ctx), models.ErrRequestTimeout) {
+		return &models.AIError{
+			Kind: models.ErrorKindTimeout,
+			Err:  fmt.Errorf("%w: %w", models.ErrRequestTimeout, err),
+		}
+	}
+	return err
+}
+
+// AdaptiveTokensPerSecond is the output speed AdaptMaxTokens assumes. Tune
+// it to the slowest model in use; a lower value trims more.
+var AdaptiveTokensPerSecond = 40.0
+
+// AdaptiveTokensOverhead is the time AdaptMaxTokens reserves for the round
+// trip and prompt processing before the first output token.
+var AdaptiveTokensOverhead = 2 * time.Second
+
+// AdaptiveMinTokens is the smallest MaxTokens AdaptMaxTokens sets, so that
+// a nearly expired deadline still asks for a usable answer.
+var AdaptiveMinTokens int64 = 64
+
+// AdaptMaxTokens lowers opts.MaxTokens to what can be generated before ctx's
+// deadline when opts.AdaptiveTokens is set. The budget is
+// (remaining time - AdaptiveTokensOverhead) * AdaptiveTokensPerSecond, but
+// at least AdaptiveMinTokens. MaxTokens is never raised, and opts are
+// returned unchanged without a deadline.
+func AdaptMaxTokens(ctx context.Context, opts models.AIClientOptions) models.AIClientOptions {
+	if !opts.AdaptiveTokens {
+		return opts
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return opts
+	}
+
+	remaining := time.Until(deadline) - AdaptiveTokensOverhead
+	budget := max(int64(remaining.Seconds()*AdaptiveTokensPerSecond), AdaptiveMinTokens)
+	if opts.MaxTokens <= 0 || budget < opts.MaxTokens {
+		opts.MaxTokens = budget
+	}
+	return opts
+}
@@ -0,0 +1,117 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tiktoken-go/tokenizer"
+)
+
+// codecs caches the tiktoken codec of each model, or nil when the model has
+// no known encoding. Building a codec loads its whole vocabulary.
+var codecs sync.Map
+
+// codecFor returns the tiktoken codec of an OpenAI model.
+func codecFor(model string) (tokenizer.Codec, bool) {
+	if model == "" {
+		return nil, false
+	}
+	if cached, ok := codecs.Load(model); ok {
+		codec, _ := cached.(tokenizer.Codec)
+		return codec, codec != nil
+	}
+
+	codec, err := tokenizer.ForModel(tokenizer.Model(model))
+	if err != nil {
+		codecs.Store(model, nil)
+		return nil, false
+	}
+	codecs.Store(model, codec)
+	return codec, true
+}
+
+// CountTokens returns the number of tokens text takes for model. OpenAI
+// models are counted exactly with their tiktoken encoding. Other models,
+// including Gemini and Claude whose tokenizers are not public, fall back to
+// EstimateTokens.
+func CountTokens(text string, model string) (int, error) {
+	codec, ok := codecFor(model)
+	if !ok {
+		return EstimateTokens(text), nil
+	}
+
+	count, err := codec.Count(text)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tokens: %w", err)
+	}
+	return count, nil
+}
+
+// tokenEstimate approximates tokenizer output without a vocabulary: each
+// CJK character counts as one token and other text as one token per four
+// bytes, which errs on the high side for both Chinese and English prose.
+type tokenEstimate struct {
+	cjk   int
+	bytes int
+}
+
+func (e *tokenEstimate) add(r rune) {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		e.cjk++
+		return
+	}
+	e.bytes += utf8.RuneLen(r)
+}
+
+func (e *tokenEstimate) tokens() int {
+	return e.cjk + (e.bytes+3)/4
+}
+
+// EstimateTokens returns an estimated token count of text that works for
+// any model. It tends to overestimate rather than underestimate.
+func EstimateTokens(text string) int {
+	var e tokenEstimate
+	for _, r := range text {
+		e.add(r)
+	}
+	return e.tokens()
+}
+
+// TruncateToTokens cuts text so that it fits in maxTokens tokens for
+// model, reporting whether anything was cut. OpenAI models are cut on exact
+// token boundaries; other models use EstimateTokens.
+func TruncateToTokens(text string, maxTokens int, model string) (string, bool) {
+	if codec, ok := codecFor(model); ok {
+		if ids, _, err := codec.Encode(text); err == nil {
+			if len(ids) <= maxTokens {
+				return text, false
+			}
+			if maxTokens <= 0 {
+				return "", true
+			}
+			if truncated, err := codec.Decode(ids[:maxTokens]); err == nil {
+				// The cut may split a multi-byte character across tokens.
+				return strings.ToValidUTF8(truncated, ""), true
+			}
+		}
+	}
+
+	if EstimateTokens(text) <= maxTokens {
+		return text, false
+	}
+	if maxTokens <= 0 {
+		return "", true
+	}
+
+	var e tokenEstimate
+	for i, r := range text {
+		e.add(r)
+		if e.tokens() > maxTokens {
+			return text[:i], true
+		}
+	}
+	return text, false
+}
@@ -0,0 +1,29 @@
+package util
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+const modulePath = "github.com/A-pen-app/ai-client"
+
+// DefaultUserAgent returns the User-Agent the provider clients send unless
+// one is configured: "ai-client/<version>", where version is this module's
+// version as recorded in the binary's build info, or "dev" when it is not
+// known.
+var DefaultUserAgent = sync.OnceValue(func() string {
+	version := "dev"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		module := &info.Main
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				module = dep
+				break
+			}
+		}
+		if module.Path == modulePath && module.Version != "" && module.Version != "(devel)" {
+			version = module.Version
+		}
+	}
+	return "ai-client/" + version
+})
@@ -2,37 +2,323 @@ package util
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/A-pen-app/ai-client/models"
+	"golang.org/x/sync/errgroup"
 )
 
-var httpClient = &http.Client{
-	Timeout: time.Second * 30,
+var httpClient = &http.Client{}
+
+// DownloadTimeout bounds a single image download attempt, including
+// reading the body. Set it to 0 or less to rely on the caller's context
+// alone.
+var DownloadTimeout = 30 * time.Second
+
+// DownloadRetries is how many times a download is retried after a
+// transient failure: a network error, a timed-out attempt, or a 408, 429 or
+// 5xx response. Other failures, such as a 404, are not retried. Set it to 0
+// to disable. These retries are independent of store.NewRetryingClient,
+// which does not retry failed downloads.
+var DownloadRetries = 2
+
+// DownloadRetryBackoff is the delay before the first download retry. It
+// doubles with each further retry.
+var DownloadRetryBackoff = 250 * time.Millisecond
+
+// DownloadConcurrency is how many ImageUrls LoadImages downloads at once.
+var DownloadConcurrency = 4
+
+// MaxImageBytes caps the size of a downloaded image. Set it to 0 or less to
+// disable the limit.
+var MaxImageBytes int64 = 20 << 20
+
+// ErrImageTooLarge is returned, wrapped, when an image exceeds MaxImageBytes.
+var ErrImageTooLarge = errors.New("image exceeds maximum size")
+
+// MaxMediaBytes caps the size of a file downloaded with DownloadMedia. Set
+// it to 0 or less to disable the limit.
+var MaxMediaBytes int64 = 25 << 20
+
+// ErrMediaTooLarge is returned, wrapped, when a file exceeds MaxMediaBytes.
+var ErrMediaTooLarge = errors.New("media exceeds maximum size")
+
+// DownloadError is returned when downloading an image or media file fails,
+// after any retries. It wraps the last failure, so errors.Is still matches
+// ErrImageTooLarge, ErrMediaTooLarge and context errors.
+type DownloadError struct {
+	// StatusCode is the HTTP status of the last failed response, or 0 when
+	// no response was received.
+	StatusCode int
+	// Transient reports whether the last failure looked temporary, so that
+	// downloading again later may succeed.
+	Transient bool
+	// Attempts is how many times the download was tried.
+	Attempts int
+	Err      error
+}
+
+func (e *DownloadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DownloadError) Unwrap() error {
+	return e.Err
 }
 
-// DownloadImage downloads an image from a URL and returns the image data
+// DownloadImage downloads an image from a URL and returns the image data.
+// It returns as soon as ctx is cancelled or DownloadTimeout elapses. A data:
+// URL is decoded instead of fetched.
 func DownloadImage(ctx context.Context, url string) ([]byte, error) {
+	data, _, err := download(ctx, url, "image", MaxImageBytes, ErrImageTooLarge)
+	return data, err
+}
+
+// DownloadMedia downloads an audio, video or document file from a URL and
+// returns its bytes and MIME type. The type comes from the response's
+// Content-Type header when it is specific, otherwise from sniffing the
+// bytes. Downloads are capped at MaxMediaBytes.
+func DownloadMedia(ctx context.Context, url string) ([]byte, string, error) {
+	data, header, err := download(ctx, url, "media", MaxMediaBytes, ErrMediaTooLarge)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, detectMediaType(data, header.Get("Content-Type")), nil
+}
+
+// detectMediaType prefers the server's Content-Type over sniffing, which
+// recognizes few audio and document formats.
+func detectMediaType(data []byte, contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	return http.DetectContentType(data)
+}
+
+// download fetches url, failing with tooLarge once the body exceeds limit
+// bytes. kind names the file in error messages. A data: URL is decoded
+// without a request, and its media type is returned as the Content-Type.
+func download(ctx context.Context, url string, kind string, limit int64, tooLarge error) ([]byte, http.Header, error) {
+	if IsDataURL(url) {
+		data, mimeType, err := DecodeDataURL(url)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode %s: %w", kind, err)
+		}
+		if limit > 0 && int64(len(data)) > limit {
+			return nil, nil, fmt.Errorf("%w: more than %d bytes", tooLarge, limit)
+		}
+		return data, http.Header{"Content-Type": {mimeType}}, nil
+	}
+
+	backoff := DownloadRetryBackoff
+	for attempt := 1; ; attempt++ {
+		data, header, err := downloadOnce(ctx, url, kind, limit, tooLarge)
+		if err == nil {
+			return data, header, nil
+		}
+
+		err.Attempts = attempt
+		// The caller's context ending is final, even when the attempt
+		// itself looked transient.
+		if ctx.Err() != nil {
+			err.Transient = false
+		}
+		if !err.Transient || attempt > DownloadRetries {
+			return nil, nil, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err.Transient = false
+			return nil, nil, err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// downloadOnce makes a single download attempt, bounded by DownloadTimeout.
+func downloadOnce(ctx context.Context, url string, kind string, limit int64, tooLarge error) ([]byte, http.Header, *DownloadError) {
+	if DownloadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DownloadTimeout)
+		defer cancel()
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, &DownloadError{Err: fmt.Errorf("failed to create request: %w", err)}
 	}
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download image: %w", err)
+		return nil, nil, &DownloadError{Transient: true, Err: fmt.Errorf("failed to download %s: %w", kind, err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to download image: status code %d", resp.StatusCode)
+		return nil, nil, &DownloadError{
+			StatusCode: resp.StatusCode,
+			Transient:  transientStatus(resp.StatusCode),
+			Err:        fmt.Errorf("failed to download %s: status code %d", kind, resp.StatusCode),
+		}
+	}
+
+	if limit > 0 && resp.ContentLength > limit {
+		return nil, nil, &DownloadError{Err: fmt.Errorf("%w: content length %d exceeds %d bytes", tooLarge, resp.ContentLength, limit)}
 	}
 
-	data, err := io.ReadAll(resp.Body)
+	var body io.Reader = resp.Body
+	if limit > 0 {
+		body = io.LimitReader(resp.Body, limit+1)
+	}
+
+	data, err := io.ReadAll(body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read image data: %w", err)
+		return nil, nil, &DownloadError{Transient: true, Err: fmt.Errorf("failed to read %s data: %w", kind, err)}
+	}
+	if limit > 0 && int64(len(data)) > limit {
+		return nil, nil, &DownloadError{Err: fmt.Errorf("%w: more than %d bytes", tooLarge, limit)}
+	}
+
+	return data, resp.Header, nil
+}
+
+// transientStatus reports whether a download that failed with statusCode
+// may succeed when tried again.
+func transientStatus(statusCode int) bool {
+	switch {
+	case statusCode == http.StatusRequestTimeout, statusCode == http.StatusTooManyRequests:
+		return true
+	case statusCode >= 500:
+		return statusCode != http.StatusNotImplemented
+	}
+	return false
+}
+
+// LoadImages downloads the message's ImageUrls and returns them, in order,
+// followed by the message's inline Images. Up to DownloadConcurrency images
+// are fetched in parallel; the first failure cancels the remaining downloads.
+// Downloaded images get the message's ImageMimeType, or else the image type
+// a data: URL declares, or else the type from DetectImageType.
+func LoadImages(ctx context.Context, message models.AIChatMessage) ([]models.ImageData, error) {
+	return LoadImagesWith(ctx, message, nil)
+}
+
+// LoadImagesWith is LoadImages with resolver fetching the ImageUrls; a nil
+// resolver is HTTPImageResolver. The type resolver reports is used like a
+// Content-Type header, and images are capped at MaxImageBytes. Failures are
+// returned as a *DownloadError.
+func LoadImagesWith(ctx context.Context, message models.AIChatMessage, resolver ImageResolver) ([]models.ImageData, error) {
+	if resolver == nil {
+		resolver = HTTPImageResolver{}
+	}
+
+	images, err := loadAll(ctx, message.ImageUrls, func(ctx context.Context, ref string) (models.ImageData, error) {
+		imageData, declared, err := resolveImage(ctx, resolver, ref)
+		if err != nil {
+			return models.ImageData{}, fmt.Errorf("failed to download image: %w", err)
+		}
+		mimeType := message.ImageMimeType
+		if mimeType == "" && IsDataURL(ref) && strings.HasPrefix(declared, "image/") {
+			mimeType = declared
+		}
+		if mimeType == "" {
+			mimeType = DetectImageType(ctx, imageData, declared, ref)
+		}
+		return models.ImageData{
+			Bytes:    imageData,
+			MimeType: mimeType,
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(images, InlineImages(message)...), nil
+}
+
+// InlineImages returns the message's inline Images, giving those without a
+// MimeType the message's ImageMimeType.
+func InlineImages(message models.AIChatMessage) []models.ImageData {
+	if message.ImageMimeType == "" {
+		return message.Images
+	}
+
+	images := make([]models.ImageData, len(message.Images))
+	for i, image := range message.Images {
+		images[i] = image
+		if image.MimeType == "" {
+			images[i].MimeType = message.ImageMimeType
+		}
+	}
+	return images
+}
+
+// LoadAudio downloads the message's AudioUrls and returns them, in order,
+// followed by the message's inline Audio, with the same concurrency as
+// LoadImages.
+func LoadAudio(ctx context.Context, message models.AIChatMessage) ([]models.AudioData, error) {
+	audio, err := loadAll(ctx, message.AudioUrls, func(ctx context.Context, url string) (models.AudioData, error) {
+		data, mimeType, err := DownloadMedia(ctx, url)
+		if err != nil {
+			return models.AudioData{}, fmt.Errorf("failed to download audio: %w", err)
+		}
+		return models.AudioData{
+			Bytes:    data,
+			MimeType: mimeType,
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(audio, message.Audio...), nil
+}
+
+// LoadDocuments downloads the message's DocumentUrls and returns them in
+// order, with the same concurrency as LoadImages.
+func LoadDocuments(ctx context.Context, message models.AIChatMessage) ([]models.DocumentData, error) {
+	return loadAll(ctx, message.DocumentUrls, func(ctx context.Context, url string) (models.DocumentData, error) {
+		data, mimeType, err := DownloadMedia(ctx, url)
+		if err != nil {
+			return models.DocumentData{}, fmt.Errorf("failed to download document: %w", err)
+		}
+		return models.DocumentData{
+			Bytes:    data,
+			MimeType: mimeType,
+		}, nil
+	})
+}
+
+// loadAll fetches every URL with up to DownloadConcurrency calls in flight,
+// keeping the results in URL order. The first failure cancels the rest.
+func loadAll[T any](ctx context.Context, urls []string, fetch func(context.Context, string) (T, error)) ([]T, error) {
+	results := make([]T, len(urls))
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(DownloadConcurrency, 1))
+	for i, url := range urls {
+		g.Go(func() error {
+			result, err := fetch(ctx, url)
+			if err != nil {
+				return err
+			}
+			results[i] = result
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
 	}
 
-	return data, nil
+	return results, nil
 }
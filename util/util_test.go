@@ -0,0 +1,197 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/A-pen-app/ai-client/models"
+)
+
+// setDownloadTimeout sets DownloadTimeout for the duration of the test.
+func setDownloadTimeout(t testing.TB, timeout time.Duration) {
+	old := DownloadTimeout
+	DownloadTimeout = timeout
+	t.Cleanup(func() { DownloadTimeout = old })
+}
+
+// setDownloadRetries sets DownloadRetries for the duration of the test.
+func setDownloadRetries(t testing.TB, retries int) {
+	old := DownloadRetries
+	DownloadRetries = retries
+	t.Cleanup(func() { DownloadRetries = old })
+}
+
+// slowServer returns a server whose responses take delay, or until the
+// client gives up.
+func slowServer(t testing.TB, delay time.Duration) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\n"))
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDownloadImageTimeout(t *testing.T) {
+	setDownloadTimeout(t, 50*time.Millisecond)
+	setDownloadRetries(t, 0)
+	srv := slowServer(t, 5*time.Second)
+
+	start := time.Now()
+	_, err := DownloadImage(context.Background(), srv.URL)
+	elapsed := time.Since(start)
+
+	if elapsed > time.Second {
+		t.Errorf("DownloadImage returned after %s, want shortly after the %s timeout", elapsed, DownloadTimeout)
+	}
+	var downloadErr *DownloadError
+	if !errors.As(err, &downloadErr) {
+		t.Fatalf("DownloadImage error = %v, want a *DownloadError", err)
+	}
+	if !downloadErr.Transient {
+		t.Errorf("a timed-out download should be transient: %v", err)
+	}
+	if downloadErr.Attempts != 1 {
+		t.Errorf("Attempts = %d, want 1", downloadErr.Attempts)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DownloadImage error = %v, want it to wrap context.DeadlineExceeded", err)
+	}
+}
+
+func TestDownloadImageContextCancelled(t *testing.T) {
+	setDownloadTimeout(t, 0)
+	srv := slowServer(t, 5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := DownloadImage(ctx, srv.URL)
+	elapsed := time.Since(start)
+
+	if elapsed > time.Second {
+		t.Errorf("DownloadImage returned after %s, want shortly after the context ended", elapsed)
+	}
+	var downloadErr *DownloadError
+	if !errors.As(err, &downloadErr) {
+		t.Fatalf("DownloadImage error = %v, want a *DownloadError", err)
+	}
+	// The caller's context ending is final and not retried.
+	if downloadErr.Transient {
+		t.Errorf("a download cancelled by the caller should not be transient: %v", err)
+	}
+	if downloadErr.Attempts != 1 {
+		t.Errorf("Attempts = %d, want 1", downloadErr.Attempts)
+	}
+}
+
+// setDownloadConcurrency sets DownloadConcurrency for the duration of the
+// test.
+func setDownloadConcurrency(t testing.TB, concurrency int) {
+	old := DownloadConcurrency
+	DownloadConcurrency = concurrency
+	t.Cleanup(func() { DownloadConcurrency = old })
+}
+
+// imageURLs returns n URLs on srv, /0 to /n-1.
+func imageURLs(srv *httptest.Server, n int) []string {
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("%s/%d", srv.URL, i)
+	}
+	return urls
+}
+
+func TestLoadImagesKeepsOrder(t *testing.T) {
+	// Later images answer first, so completion order is the reverse of the
+	// URL order.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		time.Sleep(time.Duration(5-i) * 10 * time.Millisecond)
+		w.Header().Set("Content-Type", "image/png")
+		fmt.Fprintf(w, "image %d", i)
+	}))
+	defer srv.Close()
+
+	images, err := LoadImages(context.Background(), models.AIChatMessage{ImageUrls: imageURLs(srv, 5)})
+	if err != nil {
+		t.Fatalf("LoadImages: %v", err)
+	}
+	if len(images) != 5 {
+		t.Fatalf("LoadImages returned %d images, want 5", len(images))
+	}
+	for i, image := range images {
+		if want := fmt.Sprintf("image %d", i); string(image.Bytes) != want {
+			t.Errorf("image %d = %q, want %q", i, image.Bytes, want)
+		}
+	}
+}
+
+func TestLoadImagesCancelsRemainingDownloads(t *testing.T) {
+	setDownloadRetries(t, 0)
+
+	var completed atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/0" {
+			http.NotFound(w, r)
+			return
+		}
+		select {
+		case <-time.After(5 * time.Second):
+			completed.Add(1)
+			w.Write([]byte("\x89PNG\r\n\x1a\n"))
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, err := LoadImages(context.Background(), models.AIChatMessage{ImageUrls: imageURLs(srv, 5)})
+	elapsed := time.Since(start)
+
+	var downloadErr *DownloadError
+	if !errors.As(err, &downloadErr) || downloadErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("LoadImages error = %v, want the 404 of the first image", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("LoadImages returned after %s, want the failure to cancel the other downloads", elapsed)
+	}
+	if n := completed.Load(); n > 0 {
+		t.Errorf("%d downloads completed after the first failure", n)
+	}
+}
+
+func BenchmarkLoadImages(b *testing.B) {
+	const latency = 20 * time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	}))
+	defer srv.Close()
+
+	message := models.AIChatMessage{ImageUrls: imageURLs(srv, 5)}
+	for _, concurrency := range []int{1, DownloadConcurrency} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			setDownloadConcurrency(b, concurrency)
+			for b.Loop() {
+				if _, err := LoadImages(context.Background(), message); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}